@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"image/jpeg"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+
+	p4p "github.com/pic4pdf/lib-p4p"
+
+	"github.com/pic4pdf/pic4pdf/internal/document"
+	"github.com/pic4pdf/pic4pdf/internal/export"
+	"github.com/pic4pdf/pic4pdf/internal/gui"
+	"github.com/pic4pdf/pic4pdf/internal/pdf"
+	"github.com/pic4pdf/pic4pdf/internal/sign"
+)
+
+// Page size presets by lower case name.
+var pageSizes = map[string]func() p4p.PageSize{
+	"a1":      p4p.A1,
+	"a2":      p4p.A2,
+	"a3":      p4p.A3,
+	"a4":      p4p.A4,
+	"a5":      p4p.A5,
+	"a6":      p4p.A6,
+	"letter":  p4p.Letter,
+	"legal":   p4p.Legal,
+	"tabloid": p4p.Tabloid,
+}
+
+var encodings = map[string]export.Encoding{
+	"lossy":    export.Lossy,
+	"lossless": export.Lossless,
+	"auto":     export.Auto,
+}
+
+var colourModes = map[string]document.ColourMode{
+	"keep":      document.KeepColours,
+	"grayscale": document.Grayscale,
+	"bw":        document.BlackAndWhite,
+}
+
+var permissions = map[string]pdf.Permissions{
+	"print":  pdf.PermitPrint,
+	"copy":   pdf.PermitCopy,
+	"modify": pdf.PermitModify,
+}
+
+var layoutModes = map[string]p4p.Mode{
+	"center": p4p.Center,
+	"fill":   p4p.Fill,
+	"fit":    p4p.Fit,
+}
+
+// Runs "pic4pdf export", which writes a PDF without opening a window, and
+// returns the exit code.
+func runExport(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: pic4pdf export [flags] -o output.pdf image|folder...")
+		fs.PrintDefaults()
+	}
+	output := fs.String("o", "", "output `file`")
+	pageSize := fs.String("page-size", "A4", "page size: A1 to A6, Letter, Legal or Tabloid")
+	landscape := fs.Bool("landscape", false, "rotate the page size to landscape")
+	layout := fs.String("layout", "fit", "layout mode: fit, fill or center")
+	scale := fs.Float64("scale", 1, "image scale")
+	colours := fs.String("colours", "keep", "colour mode: keep, grayscale or bw (black and white document)")
+	padTo := fs.Int("pad", 0, "add blank pages until the page count is a multiple of `n`")
+	bookmarks := fs.Bool("bookmarks", false, "add a bookmark for each page, named after the file")
+	var meta export.Metadata
+	fs.StringVar(&meta.Title, "title", "", "document title")
+	fs.StringVar(&meta.Author, "author", "", "document author")
+	fs.StringVar(&meta.Subject, "subject", "", "document subject")
+	fs.StringVar(&meta.Keywords, "keywords", "", "comma-separated document keywords")
+	fs.StringVar(&meta.Creator, "creator", "pic4pdf", "application which created the content")
+	maxDPI := fs.Float64("max-dpi", 0, "downsample images with a higher resolution on the page, 0 for no limit")
+	quality := fs.Int("quality", jpeg.DefaultQuality, "JPEG quality, 1 to 100")
+	photos := fs.String("photos", "auto", "encoding of photos (JPEG and RAW files): auto, lossy or lossless")
+	other := fs.String("other", "auto", "encoding of other images: auto, lossy or lossless")
+	quantize := fs.Bool("quantize", false, "reduce losslessly encoded graphics to 256 colours if that changes few pixels")
+	targetSize := fs.String("target-size", "", "lower the resolution and JPEG quality until the PDF has at most this `size`, e.g. 10MB;\nif that is impossible, the smallest PDF is written and the exit code is 1")
+	exifDate := fs.Bool("exif-date", false, "use the earliest EXIF date of the images as creation date")
+	pdfa := fs.Bool("pdfa", false, "write a PDF/A-2b file for archiving, which can't be encrypted")
+	// Passwords aren't taken as arguments, which other users can see.
+	userPwEnv := fs.String("user-password-env", "", "encrypt with the password needed to open the PDF, read from environment `variable`")
+	userPwFile := fs.String("user-password-file", "", "encrypt with the password needed to open the PDF, read from the first line of `file`")
+	ownerPwEnv := fs.String("owner-password-env", "", "encrypt with the password granting all permissions, read from environment `variable`")
+	ownerPwFile := fs.String("owner-password-file", "", "encrypt with the password granting all permissions, read from the first line of `file`")
+	signKey := fs.String("sign-key", "", "sign with the key in this PKCS#12 (.p12, .pfx) or PEM `file`")
+	signCert := fs.String("sign-cert", "", "PEM `file` with the certificates for -sign-key, if it has none")
+	signPwEnv := fs.String("sign-password-env", "", "password of -sign-key, read from environment `variable`")
+	signPwFile := fs.String("sign-password-file", "", "password of -sign-key, read from the first line of `file`")
+	signPage := fs.Int("sign-page", 0, "show a signature stamp on page `n`, counting from the end if negative (-1 is the last page);\n0 for an invisible signature")
+	perms := fs.String("permissions", "print,copy,modify", "comma-separated permissions without the owner password: print, copy, modify or none;\nrestricting them encrypts the PDF")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	fail := func(err error) int {
+		fmt.Fprintln(os.Stderr, "pic4pdf export:", err)
+		return 1
+	}
+	if *output == "" || fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	sizeFn, ok := pageSizes[strings.ToLower(*pageSize)]
+	if !ok {
+		return fail(fmt.Errorf("unknown page size '%v'", *pageSize))
+	}
+	ps := sizeFn()
+	if *landscape {
+		ps = ps.Rotate()
+	}
+	mode, ok := layoutModes[strings.ToLower(*layout)]
+	if !ok {
+		return fail(fmt.Errorf("unknown layout mode '%v'", *layout))
+	}
+	if *quality < 1 || *quality > 100 {
+		return fail(fmt.Errorf("quality %v is not between 1 and 100", *quality))
+	}
+	photoEnc, ok := encodings[strings.ToLower(*photos)]
+	if !ok {
+		return fail(fmt.Errorf("unknown encoding '%v'", *photos))
+	}
+	otherEnc, ok := encodings[strings.ToLower(*other)]
+	if !ok {
+		return fail(fmt.Errorf("unknown encoding '%v'", *other))
+	}
+	target, err := parseTargetSize(*targetSize)
+	if err != nil {
+		return fail(err)
+	}
+	colourMode, ok := colourModes[strings.ToLower(*colours)]
+	if !ok {
+		return fail(fmt.Errorf("unknown colour mode '%v'", *colours))
+	}
+	var enc pdf.Encryption
+	if enc.UserPassword, err = readPassword(*userPwEnv, *userPwFile); err != nil {
+		return fail(err)
+	}
+	if enc.OwnerPassword, err = readPassword(*ownerPwEnv, *ownerPwFile); err != nil {
+		return fail(err)
+	}
+	for _, p := range strings.Split(*perms, ",") {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p == "" || p == "none" {
+			continue
+		}
+		perm, ok := permissions[p]
+		if !ok {
+			return fail(fmt.Errorf("unknown permission '%v'", p))
+		}
+		enc.Permissions |= perm
+	}
+	var sig *export.Signature
+	if *signKey != "" {
+		pw, err := readPassword(*signPwEnv, *signPwFile)
+		if err != nil {
+			return fail(err)
+		}
+		signer, err := sign.Load(*signKey, *signCert, pw)
+		if err != nil {
+			return fail(fmt.Errorf("can't load signing key: %w", err))
+		}
+		sig = &export.Signature{Signer: signer, StampPage: *signPage}
+	}
+
+	paths, _, err := argPaths(fs.Args(), validFilename)
+	if err != nil {
+		return fail(err)
+	}
+	if len(paths) == 0 {
+		return fail(fmt.Errorf("no images given"))
+	}
+	pages := make([]document.Page, len(paths))
+	for i, path := range paths {
+		pages[i] = document.Page{ID: i + 1, Path: path}
+	}
+	if *exifDate {
+		meta.CreationDate = export.EarliestExifDate(pages)
+	}
+
+	opts := export.Options{
+		PageSize: ps,
+		Image: p4p.ImageOptions{
+			Mode:  mode,
+			Scale: *scale,
+		},
+		PadTo:         *padTo,
+		Margin:        gui.DefaultMargin,
+		PageBookmarks: *bookmarks,
+		Metadata:      meta,
+		MaxDPI:        *maxDPI,
+		Quality:       *quality,
+		PhotoEncoding: photoEnc,
+		OtherEncoding: otherEnc,
+		Quantize:      *quantize,
+		ColourMode:    colourMode,
+		PDFA:          *pdfa,
+		Signature:     sig,
+	}
+	if enc.UserPassword != "" || enc.OwnerPassword != "" || enc.Permissions != pdf.PermitAll {
+		opts.Encryption = &enc
+	}
+	var notMet *export.TargetSizeError
+	if target > 0 {
+		opts, _, err = export.FitSize(context.Background(), pages, opts, target)
+		if err != nil && !errors.As(err, &notMet) {
+			return fail(err)
+		}
+	}
+
+	f, err := os.Create(*output)
+	if err != nil {
+		return fail(err)
+	}
+	err = export.Write(f, pages, opts)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(*output)
+		return fail(err)
+	}
+	if notMet != nil {
+		return fail(fmt.Errorf("target size not met, the smallest PDF possible has %v", formatSize(notMet.Closest)))
+	}
+	return 0
+}
+
+func runVerify(args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: pic4pdf verify file.pdf")
+		fmt.Fprintln(fs.Output(), "Checks the signature of a PDF exported by pic4pdf. The exit code is 0 if it is valid\nand the file wasn't changed after signing.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	fail := func(err error) int {
+		fmt.Fprintln(os.Stderr, "pic4pdf verify:", err)
+		return 1
+	}
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return fail(err)
+	}
+	results, err := sign.VerifyPDF(data)
+	if err != nil {
+		return fail(err)
+	}
+	code := 0
+	for _, r := range results {
+		cert := r.Chain[0]
+		fmt.Println("Valid signature")
+		fmt.Println("  Signed by:", cert.Subject)
+		fmt.Println("  Issued by:", cert.Issuer)
+		if r.Time.IsZero() {
+			fmt.Println("  Signed at: unknown")
+		} else {
+			fmt.Println("  Signed at:", r.Time.Local().Format("2006-01-02 15:04:05 -07:00"))
+		}
+		if r.TrustErr != nil {
+			fmt.Println("  Certificate: not trusted by this system:", r.TrustErr)
+		} else {
+			fmt.Println("  Certificate: trusted by this system")
+		}
+		if !r.WholeFile {
+			fmt.Println("  The file was changed after signing.")
+			code = 1
+		}
+	}
+	return code
+}
+
+// Returns the password in the environment variable env or the first line of
+// file, whichever is given, or "" if neither is.
+func readPassword(env, file string) (string, error) {
+	switch {
+	case env != "" && file != "":
+		return "", fmt.Errorf("a password can't be read from both an environment variable and a file")
+	case env != "":
+		pw, ok := os.LookupEnv(env)
+		if !ok {
+			return "", fmt.Errorf("environment variable %v is not set", env)
+		}
+		return pw, nil
+	case file != "":
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		pw, _, _ := strings.Cut(string(data), "\n")
+		return strings.TrimSuffix(pw, "\r"), nil
+	}
+	return "", nil
+}
+
+// Parses a size like "10 MB", "500kB" or "2000000" (bytes). Units are
+// decimal, as in formatSize. An empty string gives 0.
+func parseTargetSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	num := strings.TrimRightFunc(s, unicode.IsLetter)
+	unit := strings.ToLower(s[len(num):])
+	mult, ok := map[string]float64{"": 1, "b": 1, "k": 1e3, "kb": 1e3, "m": 1e6, "mb": 1e6, "g": 1e9, "gb": 1e9}[unit]
+	v, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+	if !ok || err != nil || v <= 0 {
+		return 0, fmt.Errorf("invalid target size '%v'", s)
+	}
+	return int64(v * mult), nil
+}
@@ -7,7 +7,11 @@ require (
 	github.com/adrg/xdg v0.4.0
 	github.com/deepakjois/gousbdrivedetector v0.0.0-20220514003247-ea439de1c459
 	github.com/fsnotify/fsnotify v1.7.0
+	github.com/godbus/dbus/v5 v5.1.0
+	github.com/nwaples/rardecode v1.1.3
 	github.com/pic4pdf/lib-p4p v0.0.0-20240219003935-f35b4cb3ebd6
+	github.com/rwcarlsen/goexif v0.0.0-20190401172101-9e8deecbddbd
+	golang.design/x/clipboard v0.7.0
 	golang.org/x/image v0.11.0
 )
 
@@ -22,7 +26,6 @@ require (
 	github.com/go-gl/glfw/v3.3/glfw v0.0.0-20221017161538-93cebf72946b // indirect
 	github.com/go-text/render v0.0.0-20230619120952-35bccb6164b8 // indirect
 	github.com/go-text/typesetting v0.0.0-20230616162802-9c17dd34aa4a // indirect
-	github.com/godbus/dbus/v5 v5.1.0 // indirect
 	github.com/gopherjs/gopherjs v1.17.2 // indirect
 	github.com/jsummers/gobmp v0.0.0-20151104160322-e2ba15ffa76e // indirect
 	github.com/jung-kurt/gofpdf v1.16.2 // indirect
@@ -32,6 +35,7 @@ require (
 	github.com/stretchr/testify v1.8.4 // indirect
 	github.com/tevino/abool v1.2.0 // indirect
 	github.com/yuin/goldmark v1.5.5 // indirect
+	golang.org/x/exp v0.0.0-20200224162631-6cc2880d07d6 // indirect
 	golang.org/x/mobile v0.0.0-20230531173138-3c911d8e3eda // indirect
 	golang.org/x/net v0.17.0 // indirect
 	golang.org/x/sys v0.13.0 // indirect
@@ -0,0 +1,322 @@
+// Package archive lets archive files (.zip, .cbz, .tar, .rar, ...) be browsed
+// like directories.
+//
+// All functions accept both regular paths and paths leading into an archive,
+// e.g. "/home/user/comic.cbz/chapter1/01.jpg". Archive files themselves are
+// reported as directories.
+package archive
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pic4pdf/pic4pdf/internal/natsort"
+)
+
+// Returned for archive entries whose name would lead outside of the archive.
+var ErrInsecurePath = errors.New("insecure path in archive")
+
+// An archive format. Entries are only read when opened.
+type format interface {
+	// Lists all regular files in the archive at path.
+	list(path string) ([]*entry, error)
+	// Opens e for reading. e must have been returned by list.
+	open(path string, e *entry) (io.ReadCloser, error)
+}
+
+var formats = []struct {
+	ext string
+	fmt format
+}{
+	{".zip", zipFormat{}},
+	{".cbz", zipFormat{}},
+	{".tar", tarFormat{}},
+	{".cbt", tarFormat{}},
+	{".tar.gz", tarFormat{gzip: true}},
+	{".tgz", tarFormat{gzip: true}},
+	{".rar", rarFormat{}},
+	{".cbr", rarFormat{}},
+}
+
+func formatOf(name string) format {
+	name = strings.ToLower(name)
+	for _, f := range formats {
+		if strings.HasSuffix(name, f.ext) {
+			return f.fmt
+		}
+	}
+	return nil
+}
+
+// Reports whether name has the extension of a supported archive format.
+func IsArchive(name string) bool {
+	return formatOf(name) != nil
+}
+
+type entry struct {
+	name    string
+	size    int64
+	modTime time.Time
+	// Position of the entry in the archive.
+	idx int
+}
+
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return fi.size }
+func (fi *fileInfo) Mode() fs.FileMode  { return fi.mode }
+func (fi *fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *fileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi *fileInfo) Sys() any           { return nil }
+
+type index struct {
+	modTime time.Time
+	size    int64
+	fmt     format
+	files   map[string]*entry
+	// Directory contents keyed by directory name, "" being the root.
+	dirs map[string][]fs.DirEntry
+}
+
+var (
+	indexesMu sync.Mutex
+	indexes   = make(map[string]*index)
+)
+
+// Cleans an entry name and checks that it stays inside the archive.
+func cleanName(name string) (string, error) {
+	name = path.Clean(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || !fs.ValidPath(name) {
+		return "", ErrInsecurePath
+	}
+	return name, nil
+}
+
+// Returns the index of the archive at p, reading it if it changed since last time.
+func loadIndex(p string) (*index, error) {
+	st, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	indexesMu.Lock()
+	idx, ok := indexes[p]
+	indexesMu.Unlock()
+	if ok && idx.modTime.Equal(st.ModTime()) && idx.size == st.Size() {
+		return idx, nil
+	}
+
+	f := formatOf(p)
+	ents, err := f.list(p)
+	if err != nil {
+		return nil, err
+	}
+	idx = &index{
+		modTime: st.ModTime(),
+		size:    st.Size(),
+		fmt:     f,
+		files:   make(map[string]*entry),
+		dirs:    map[string][]fs.DirEntry{"": nil},
+	}
+	for _, e := range ents {
+		name, err := cleanName(e.name)
+		if err != nil {
+			log.Printf("archive: %v: skipping entry %q: %v", p, e.name, err)
+			continue
+		}
+		if _, ok := idx.files[name]; ok {
+			continue
+		}
+		e.name = name
+		idx.files[name] = e
+		// Register the file and all its parent directories.
+		child := fs.FileInfoToDirEntry(&fileInfo{
+			name:    path.Base(name),
+			size:    e.size,
+			mode:    0o444,
+			modTime: e.modTime,
+		})
+		for dir := path.Dir(name); ; dir = path.Dir(dir) {
+			if dir == "." {
+				dir = ""
+			}
+			_, exists := idx.dirs[dir]
+			idx.dirs[dir] = append(idx.dirs[dir], child)
+			if exists || dir == "" {
+				break
+			}
+			child = fs.FileInfoToDirEntry(&fileInfo{
+				name:    path.Base(dir),
+				mode:    fs.ModeDir | 0o555,
+				modTime: e.modTime,
+			})
+		}
+	}
+	for _, ents := range idx.dirs {
+		sortEntries(ents)
+	}
+
+	indexesMu.Lock()
+	indexes[p] = idx
+	indexesMu.Unlock()
+	return idx, nil
+}
+
+func sortEntries(ents []fs.DirEntry) {
+	sort.SliceStable(ents, func(i, j int) bool {
+		return natsort.Less(ents[i].Name(), ents[j].Name())
+	})
+}
+
+// Splits p into the path of an archive file and the name of an entry inside
+// of it. ok is false if p does not lead into an archive.
+//
+// entry is empty if p is the archive itself.
+func Split(p string) (archivePath, entry string, ok bool) {
+	p = filepath.Clean(p)
+	for i := 1; i <= len(p); i++ {
+		if i < len(p) && p[i] != filepath.Separator {
+			continue
+		}
+		prefix := p[:i]
+		if !IsArchive(prefix) {
+			continue
+		}
+		if st, err := os.Stat(prefix); err == nil && st.Mode().IsRegular() {
+			return prefix, strings.TrimPrefix(filepath.ToSlash(p[i:]), "/"), true
+		}
+	}
+	return "", "", false
+}
+
+// Presents an archive file as a directory.
+func asDir(info fs.FileInfo) fs.FileInfo {
+	return &fileInfo{
+		name:    info.Name(),
+		size:    info.Size(),
+		mode:    fs.ModeDir | info.Mode().Perm(),
+		modTime: info.ModTime(),
+	}
+}
+
+// Like os.ReadDir, but also lists the contents of archives.
+// Archive files are reported as directories.
+func ReadDir(p string) ([]fs.DirEntry, error) {
+	arPath, name, ok := Split(p)
+	if !ok {
+		ents, err := os.ReadDir(p)
+		for i, ent := range ents {
+			if ent.Type().IsRegular() && IsArchive(ent.Name()) {
+				if info, err := ent.Info(); err == nil {
+					ents[i] = fs.FileInfoToDirEntry(asDir(info))
+				}
+			}
+		}
+		return ents, err
+	}
+	idx, err := loadIndex(arPath)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: p, Err: err}
+	}
+	ents, ok := idx.dirs[name]
+	if !ok {
+		if _, isFile := idx.files[name]; isFile {
+			return nil, &fs.PathError{Op: "readdir", Path: p, Err: errors.New("not a directory")}
+		}
+		return nil, &fs.PathError{Op: "readdir", Path: p, Err: fs.ErrNotExist}
+	}
+	res := make([]fs.DirEntry, len(ents))
+	copy(res, ents)
+	return res, nil
+}
+
+// Like os.Stat, but also works inside archives.
+// Archive files are reported as directories.
+func Stat(p string) (fs.FileInfo, error) {
+	arPath, name, ok := Split(p)
+	if !ok {
+		return os.Stat(p)
+	}
+	if name == "" {
+		info, err := os.Stat(arPath)
+		if err != nil {
+			return nil, err
+		}
+		return asDir(info), nil
+	}
+	idx, err := loadIndex(arPath)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: p, Err: err}
+	}
+	if e, ok := idx.files[name]; ok {
+		return &fileInfo{
+			name:    path.Base(name),
+			size:    e.size,
+			mode:    0o444,
+			modTime: e.modTime,
+		}, nil
+	}
+	if _, ok := idx.dirs[name]; ok {
+		return &fileInfo{
+			name: path.Base(name),
+			mode: fs.ModeDir | 0o555,
+		}, nil
+	}
+	return nil, &fs.PathError{Op: "stat", Path: p, Err: fs.ErrNotExist}
+}
+
+// Like os.Open, but also opens files inside archives.
+// Archive entries are only read once the returned reader is used.
+func Open(p string) (io.ReadCloser, error) {
+	arPath, name, ok := Split(p)
+	if !ok {
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+	idx, err := loadIndex(arPath)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: p, Err: err}
+	}
+	e, ok := idx.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: p, Err: fs.ErrNotExist}
+	}
+	rc, err := idx.fmt.open(arPath, e)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: p, Err: err}
+	}
+	return rc, nil
+}
+
+// Closes multiple closers in order, returning the first error.
+type multiCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (m *multiCloser) Close() error {
+	var res error
+	for _, c := range m.closers {
+		if err := c.Close(); err != nil && res == nil {
+			res = err
+		}
+	}
+	return res
+}
@@ -0,0 +1,80 @@
+package archive
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/nwaples/rardecode"
+)
+
+type rarFormat struct{}
+
+// Wraps errors from the RAR decoder, which does not tell us whether an
+// archive is encrypted or just damaged.
+func rarError(name string, err error) error {
+	if err == nil || errors.Is(err, io.EOF) {
+		return err
+	}
+	if name == "" {
+		return fmt.Errorf("cannot read RAR archive (it may be encrypted or damaged): %w", err)
+	}
+	return fmt.Errorf("cannot read '%v' from RAR archive (it may be encrypted or damaged): %w", name, err)
+}
+
+func (rarFormat) list(path string) ([]*entry, error) {
+	r, err := rardecode.OpenReader(path, "")
+	if err != nil {
+		return nil, rarError("", err)
+	}
+	defer r.Close()
+	var res []*entry
+	for i := 0; ; i++ {
+		hdr, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, rarError("", err)
+		}
+		if hdr.IsDir || !hdr.Mode().IsRegular() {
+			continue
+		}
+		res = append(res, &entry{
+			name:    hdr.Name,
+			size:    hdr.UnPackedSize,
+			modTime: hdr.ModificationTime,
+			idx:     i,
+		})
+	}
+	return res, nil
+}
+
+type rarReader struct {
+	io.ReadCloser
+	name string
+}
+
+func (r *rarReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	return n, rarError(r.name, err)
+}
+
+func (rarFormat) open(path string, e *entry) (io.ReadCloser, error) {
+	// In solid archives skipping an entry even means decompressing it.
+	rc, err := openSequential(path, e, func(path string) (*stream, error) {
+		r, err := rardecode.OpenReader(path, "")
+		if err != nil {
+			return nil, err
+		}
+		next := func() error {
+			_, err := r.Next()
+			return err
+		}
+		return &stream{Reader: r, Closer: r, next: next}, nil
+	})
+	if err != nil {
+		return nil, rarError(e.name, err)
+	}
+	return &rarReader{ReadCloser: rc, name: e.name}, nil
+}
@@ -0,0 +1,112 @@
+package archive
+
+import (
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// The entries of an archive which can only be read in order, like tar and RAR.
+type stream struct {
+	// Reads the current entry.
+	io.Reader
+	io.Closer
+	// Advances to the next entry, returning io.EOF after the last.
+	next func() error
+}
+
+// A stream of the archive at path, positioned at entry pos.
+type cursor struct {
+	*stream
+	path    string
+	modTime time.Time
+	size    int64
+	// -1 before the first entry.
+	pos int
+}
+
+// How long an unused cursor is kept open.
+const idleCursorTimeout = 10 * time.Second
+
+var (
+	cursorMu sync.Mutex
+	// The cursor of the entry last read. Keeping it lets entries read in
+	// order, as when exporting, be read in one pass instead of starting
+	// over for each.
+	idleCursor *cursor
+)
+
+// Opens e of the sequential archive at p, continuing from the entry last
+// read if it comes before e. open opens a new stream.
+func openSequential(p string, e *entry, open func(path string) (*stream, error)) (io.ReadCloser, error) {
+	st, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	cursorMu.Lock()
+	c := idleCursor
+	idleCursor = nil
+	cursorMu.Unlock()
+	if c != nil && (c.path != p || !c.modTime.Equal(st.ModTime()) || c.size != st.Size() || c.pos >= e.idx) {
+		c.Close()
+		c = nil
+	}
+	if c == nil {
+		s, err := open(p)
+		if err != nil {
+			return nil, err
+		}
+		c = &cursor{stream: s, path: p, modTime: st.ModTime(), size: st.Size(), pos: -1}
+	}
+	for c.pos < e.idx {
+		if err := c.next(); err != nil {
+			c.Close()
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		c.pos++
+	}
+	return &entryReader{c: c}, nil
+}
+
+// Reads an entry from a cursor, which is kept for later entries on Close.
+type entryReader struct {
+	c *cursor
+}
+
+func (r *entryReader) Read(p []byte) (int, error) {
+	if r.c == nil {
+		return 0, os.ErrClosed
+	}
+	return r.c.Read(p)
+}
+
+func (r *entryReader) Close() error {
+	c := r.c
+	if c == nil {
+		return os.ErrClosed
+	}
+	r.c = nil
+	cursorMu.Lock()
+	old := idleCursor
+	idleCursor = c
+	cursorMu.Unlock()
+	time.AfterFunc(idleCursorTimeout, func() {
+		cursorMu.Lock()
+		idle := idleCursor == c
+		if idle {
+			idleCursor = nil
+		}
+		cursorMu.Unlock()
+		if idle {
+			c.Close()
+		}
+	})
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
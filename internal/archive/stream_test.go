@@ -0,0 +1,59 @@
+package archive
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenSequential(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "pages.tar.gz")
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	const n = 5
+	for i := 0; i < n; i++ {
+		data := fmt.Sprint("page ", i, " of ", n)
+		tw.WriteHeader(&tar.Header{Name: fmt.Sprint(i, ".jpg"), Mode: 0o644, Size: int64(len(data))})
+		tw.Write([]byte(data))
+	}
+	tw.Close()
+	gz.Close()
+	f.Close()
+
+	read := func(i int) io.ReadCloser {
+		t.Helper()
+		rc, err := Open(filepath.Join(p, fmt.Sprint(i, ".jpg")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Only read part of it, to check that the rest is skipped.
+		b := make([]byte, len("page 0"))
+		if _, err := io.ReadFull(rc, b); err != nil {
+			t.Fatal(err)
+		}
+		if want := fmt.Sprint("page ", i); string(b) != want {
+			t.Errorf("entry %v: read %q, want %q", i, b, want)
+		}
+		return rc
+	}
+	// In order, backwards, repeated and while another entry is open.
+	for _, i := range []int{0, 1, 3, 4, 2, 2, 0} {
+		rc := read(i)
+		rc.Close()
+	}
+	rc := read(1)
+	rc2 := read(3)
+	rc.Close()
+	rc2.Close()
+	if _, err := rc.Read(make([]byte, 1)); err != os.ErrClosed {
+		t.Errorf("read after close: got %v, want %v", err, os.ErrClosed)
+	}
+}
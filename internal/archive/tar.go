@@ -0,0 +1,72 @@
+package archive
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+)
+
+type tarFormat struct {
+	gzip bool
+}
+
+// Opens the archive at path, returning a tar reader and the closers of the
+// underlying readers.
+func (t tarFormat) reader(path string) (*tar.Reader, []io.Closer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !t.gzip {
+		return tar.NewReader(f), []io.Closer{f}, nil
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return tar.NewReader(gz), []io.Closer{gz, f}, nil
+}
+
+func (t tarFormat) list(path string) ([]*entry, error) {
+	tr, closers, err := t.reader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer (&multiCloser{closers: closers}).Close()
+	var res []*entry
+	for i := 0; ; i++ {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		res = append(res, &entry{
+			name:    hdr.Name,
+			size:    hdr.Size,
+			modTime: hdr.ModTime,
+			idx:     i,
+		})
+	}
+	return res, nil
+}
+
+func (t tarFormat) open(path string, e *entry) (io.ReadCloser, error) {
+	return openSequential(path, e, func(path string) (*stream, error) {
+		tr, closers, err := t.reader(path)
+		if err != nil {
+			return nil, err
+		}
+		next := func() error {
+			_, err := tr.Next()
+			return err
+		}
+		return &stream{Reader: tr, Closer: &multiCloser{closers: closers}, next: next}, nil
+	})
+}
@@ -0,0 +1,46 @@
+package archive
+
+import (
+	"archive/zip"
+	"io"
+)
+
+type zipFormat struct{}
+
+func (zipFormat) list(path string) ([]*entry, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	var res []*entry
+	for i, f := range r.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		res = append(res, &entry{
+			name:    f.Name,
+			size:    int64(f.UncompressedSize64),
+			modTime: f.Modified,
+			idx:     i,
+		})
+	}
+	return res, nil
+}
+
+func (zipFormat) open(path string, e *entry) (io.ReadCloser, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	if e.idx >= len(r.File) {
+		r.Close()
+		return nil, io.ErrUnexpectedEOF
+	}
+	rc, err := r.File[e.idx].Open()
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	return &multiCloser{Reader: rc, closers: []io.Closer{rc, r}}, nil
+}
@@ -0,0 +1,93 @@
+// Package bilevel converts scanned documents to black and white.
+package bilevel
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+const (
+	// Sensitivity of the Sauvola threshold. Higher values make more pixels
+	// white.
+	sauvolaK = 0.34
+	// Dynamic range of the standard deviation.
+	sauvolaR = 128
+)
+
+// Returns the luminance of img, with transparent parts on white.
+func Gray(img image.Image) *image.Gray {
+	b := img.Bounds()
+	if g, ok := img.(*image.Gray); ok && b.Min == (image.Point{}) {
+		return g
+	}
+	// Converting to RGBA is fast for common image types.
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Over)
+	gray := image.NewGray(rgba.Bounds())
+	for i := range gray.Pix {
+		p := rgba.Pix[i*4 : i*4+3]
+		gray.Pix[i] = uint8((299*int(p[0]) + 587*int(p[1]) + 114*int(p[2]) + 500) / 1000)
+	}
+	return gray
+}
+
+// Returns img in black (0) and white (0xff) using Sauvola's adaptive
+// threshold, which copes with uneven lighting and paper colour. The window
+// the threshold is computed over scales with the image size.
+func Threshold(img image.Image) *image.Gray {
+	gray := Gray(img)
+	w, h := gray.Rect.Dx(), gray.Rect.Dy()
+	// About a line of text at common scan resolutions.
+	r := max(7, min(w, h)/80)
+	// Sums of the values and squared values of each column over the rows
+	// of the window.
+	colSum := make([]int64, w)
+	colSq := make([]int64, w)
+	add := func(y int, sign int64) {
+		row := gray.Pix[y*gray.Stride : y*gray.Stride+w]
+		for x, v := range row {
+			colSum[x] += sign * int64(v)
+			colSq[x] += sign * int64(v) * int64(v)
+		}
+	}
+	for y := 0; y < min(r, h); y++ {
+		add(y, 1)
+	}
+	out := image.NewGray(gray.Rect)
+	for y := 0; y < h; y++ {
+		// The window covers rows y-r to y+r.
+		if y+r < h {
+			add(y+r, 1)
+		}
+		if y-r-1 >= 0 {
+			add(y-r-1, -1)
+		}
+		rows := min(y+r, h-1) - max(y-r, 0) + 1
+		var sum, sq int64
+		for x := 0; x < min(r, w); x++ {
+			sum += colSum[x]
+			sq += colSq[x]
+		}
+		row := gray.Pix[y*gray.Stride : y*gray.Stride+w]
+		outRow := out.Pix[y*out.Stride : y*out.Stride+w]
+		for x := 0; x < w; x++ {
+			if x+r < w {
+				sum += colSum[x+r]
+				sq += colSq[x+r]
+			}
+			if x-r-1 >= 0 {
+				sum -= colSum[x-r-1]
+				sq -= colSq[x-r-1]
+			}
+			n := float64(rows * (min(x+r, w-1) - max(x-r, 0) + 1))
+			mean := float64(sum) / n
+			dev := math.Sqrt(max(float64(sq)/n-mean*mean, 0))
+			if float64(row[x]) > mean*(1+sauvolaK*(dev/sauvolaR-1)) {
+				outRow[x] = 0xff
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,48 @@
+package bilevel
+
+import (
+	"image"
+	"testing"
+)
+
+func TestThreshold(t *testing.T) {
+	// Dark strokes on paper lit unevenly, from dim gray on the left to
+	// white on the right.
+	const w, h = 800, 600
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	ink := func(x, y int) bool {
+		return y%30 < 4 && x%50 < 35
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := 110 + 140*x/w
+			if ink(x, y) {
+				v -= 80
+			}
+			img.Pix[y*img.Stride+x] = uint8(v)
+		}
+	}
+	out := Threshold(img)
+	if out.Bounds() != img.Bounds() {
+		t.Fatalf("bounds are %v, want %v", out.Bounds(), img.Bounds())
+	}
+	wrong := 0
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			want := uint8(0xff)
+			if ink(x, y) {
+				want = 0
+			}
+			v := out.GrayAt(x, y).Y
+			if v != 0 && v != 0xff {
+				t.Fatalf("pixel (%v, %v) is %#x, neither black nor white", x, y, v)
+			}
+			if v != want {
+				wrong++
+			}
+		}
+	}
+	if wrong > w*h/100 {
+		t.Errorf("%v of %v pixels are wrong", wrong, w*h)
+	}
+}
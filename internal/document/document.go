@@ -0,0 +1,122 @@
+// Package document describes the pages of the PDF being created.
+package document
+
+import "image/color"
+
+type Kind int
+
+const (
+	// A page showing the image at Path.
+	ImagePage Kind = iota
+	// An empty page.
+	BlankPage
+	// A page showing Text.
+	TextPage
+	// Not a page, but the start of a section named Title, which contains
+	// all pages up to the next SectionStart. An empty Title ends the
+	// previous section without starting a new one.
+	SectionStart
+)
+
+type Align int
+
+const (
+	AlignLeft Align = iota
+	AlignCenter
+	AlignRight
+)
+
+// Content of a text page.
+type Text struct {
+	Heading string
+	Body    string
+	// Font size of the body in points. The heading is twice as large.
+	FontSize float64
+	Align    Align
+}
+
+// How the image of a page is compressed.
+type Compression int
+
+const (
+	// As set in the export options.
+	DefaultCompression Compression = iota
+	LossyCompression
+	LosslessCompression
+)
+
+// How the colours of an image are exported.
+type ColourMode int
+
+const (
+	// As set for the whole document; KeepColours if unset there.
+	DefaultColours ColourMode = iota
+	KeepColours
+	// Transparent parts become white.
+	Grayscale
+	// For document scans: converted to black and white with an adaptive
+	// threshold and stored with 1 bit per pixel.
+	BlackAndWhite
+)
+
+// A page of the document.
+type Page struct {
+	// Identifies the page, as the same image can be on several pages.
+	ID   int
+	Kind Kind
+	// Path of the source image; may lead into an archive. Empty unless Kind
+	// is ImagePage.
+	Path string
+	// Clockwise rotation in degrees, a multiple of 90.
+	Rotation int
+	// Overrides the compression of the image.
+	Compression Compression
+	ColourMode  ColourMode
+	// Fills blank and text pages, which are white if it is fully
+	// transparent.
+	Background color.NRGBA
+	// Content of text pages.
+	Text Text
+	// Title of a SectionStart.
+	Title string
+}
+
+// Returns the opaque colour of the page, which is Background on white paper.
+func (p Page) Colour() color.NRGBA {
+	bg := p.Background
+	blend := func(c uint8) uint8 {
+		return uint8((int(c)*int(bg.A) + 255*(255-int(bg.A))) / 255)
+	}
+	return color.NRGBA{R: blend(bg.R), G: blend(bg.G), B: blend(bg.B), A: 255}
+}
+
+// Returns the colour mode of the page, given that of the whole document.
+func (p Page) Colours(doc ColourMode) ColourMode {
+	if p.ColourMode != DefaultColours {
+		return p.ColourMode
+	}
+	if doc == DefaultColours {
+		return KeepColours
+	}
+	return doc
+}
+
+// Returns pages without SectionStarts.
+func Printed(pages []Page) []Page {
+	res := make([]Page, 0, len(pages))
+	for _, p := range pages {
+		if p.Kind != SectionStart {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// Returns r normalized to 0, 90, 180 or 270.
+func NormRotation(r int) int {
+	r %= 360
+	if r < 0 {
+		r += 360
+	}
+	return r
+}
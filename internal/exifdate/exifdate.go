@@ -0,0 +1,27 @@
+// Package exifdate reads when a photo was taken from its EXIF data.
+package exifdate
+
+import (
+	"time"
+
+	"github.com/rwcarlsen/goexif/exif"
+
+	"github.com/pic4pdf/pic4pdf/internal/archive"
+)
+
+// Returns the EXIF DateTimeOriginal (or DateTime) of the image at path,
+// which may also lead into an archive. Works for JPEGs and TIFF-based RAWs.
+//
+// EXIF dates carry no time zone, so the result is in local time.
+func Get(path string) (time.Time, error) {
+	f, err := archive.Open(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+	x, err := exif.Decode(f)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return x.DateTime()
+}
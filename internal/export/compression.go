@@ -0,0 +1,236 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"slices"
+
+	p4p "github.com/pic4pdf/lib-p4p"
+
+	"github.com/pic4pdf/pic4pdf/internal/bilevel"
+	"github.com/pic4pdf/pic4pdf/internal/document"
+	"github.com/pic4pdf/pic4pdf/internal/pdf"
+)
+
+const (
+	// Number of pixels sampled for the edge statistics.
+	maxSamples = 256 * 256
+	// Share of pixels the colours of a quantized palette must cover.
+	quantizeCoverage = 0.95
+)
+
+// How an image is encoded.
+type imageEncoding struct {
+	// Black and white using CCITT Group 4.
+	bilevel bool
+	lossy   bool
+	// Colours of an indexed image, if any.
+	palette color.Palette
+	// Whether palette doesn't hold all colours of the image.
+	quantized bool
+}
+
+// Returns the pixels of img with its bounds starting at 0, 0.
+func nrgba(img image.Image) *image.NRGBA {
+	b := img.Bounds()
+	if n, ok := img.(*image.NRGBA); ok && b.Min == (image.Point{}) {
+		return n
+	}
+	if pdf.Opaque(img) {
+		// Converting to RGBA is fast for common image types, and opaque
+		// RGBA pixels are the same as NRGBA ones.
+		rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+		return &image.NRGBA{Pix: rgba.Pix, Stride: rgba.Stride, Rect: rgba.Rect}
+	}
+	n := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(n, n.Bounds(), img, b.Min, draw.Src)
+	return n
+}
+
+// Reports whether img looks like a photo rather than a screenshot or line
+// art. Photos have few areas of exactly the same colour, as noise and
+// gradients make neighbouring pixels differ slightly.
+func looksLikePhoto(img *image.NRGBA) bool {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	if w < 2 || h < 2 {
+		return false
+	}
+	step := 1
+	for (w/step)*(h/step) > maxSamples {
+		step++
+	}
+	lum := func(p []byte) int {
+		return (299*int(p[0]) + 587*int(p[1]) + 114*int(p[2])) / 1000
+	}
+	flat, soft, total := 0, 0, 0
+	for y := 0; y < h-1; y += step {
+		for x := 0; x < w-1; x += step {
+			off := img.PixOffset(x, y)
+			p := img.Pix[off : off+4]
+			right := img.Pix[off+4 : off+8]
+			below := img.Pix[off+img.Stride : off+img.Stride+4]
+			for _, q := range [][]byte{right, below} {
+				switch d := lum(p) - lum(q); {
+				case d == 0 && p[0] == q[0] && p[1] == q[1] && p[2] == q[2]:
+					flat++
+				case d > -48 && d < 48:
+					soft++
+				}
+				total++
+			}
+		}
+	}
+	// Graphics are mostly flat, with hard edges.
+	return flat*10 < total*6 && soft*10 > total*3
+}
+
+// Returns the colours of img ignoring alpha, most frequent first, if it has
+// at most limit different ones, and the number of pixels of each.
+func countColours(img *image.NRGBA, limit int) (color.Palette, []int) {
+	counts := make(map[[3]byte]int)
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	for y := 0; y < h; y++ {
+		row := img.Pix[y*img.Stride : y*img.Stride+w*4]
+		for x := 0; x < len(row); x += 4 {
+			counts[[3]byte{row[x], row[x+1], row[x+2]}]++
+			if len(counts) > limit {
+				return nil, nil
+			}
+		}
+	}
+	keys := make([][3]byte, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b [3]byte) int {
+		if counts[a] != counts[b] {
+			return counts[b] - counts[a]
+		}
+		return slices.Compare(a[:], b[:])
+	})
+	pal := make(color.Palette, len(keys))
+	n := make([]int, len(keys))
+	for i, k := range keys {
+		pal[i] = color.NRGBA{R: k[0], G: k[1], B: k[2], A: 0xff}
+		n[i] = counts[k]
+	}
+	return pal, n
+}
+
+// Returns the palette of a losslessly encoded image, or nil if it has too
+// many colours.
+func palette(img *image.NRGBA, quantize bool) (pal color.Palette, quantized bool) {
+	if !quantize {
+		pal, _ := countColours(img, 256)
+		return pal, false
+	}
+	// Screenshots often have a few thousand colours from anti-aliasing.
+	pal, counts := countColours(img, 1<<14)
+	if len(pal) <= 256 {
+		return pal, false
+	}
+	covered := 0
+	for _, n := range counts[:256] {
+		covered += n
+	}
+	if float64(covered) < quantizeCoverage*float64(img.Rect.Dx()*img.Rect.Dy()) {
+		return nil, false
+	}
+	return pal[:256], true
+}
+
+// Returns how the image of page, as returned by prepareImage, is encoded.
+func chooseEncoding(page document.Page, img image.Image, opts Options) imageEncoding {
+	if page.Colours(opts.ColourMode) == document.BlackAndWhite {
+		return imageEncoding{bilevel: true}
+	}
+	if opts.ReencodeJPEGs && isJPEG(page.Path) {
+		return imageEncoding{lossy: true}
+	}
+	enc := opts.OtherEncoding
+	if isPhoto(page.Path) {
+		enc = opts.PhotoEncoding
+	}
+	switch page.Compression {
+	case document.LossyCompression:
+		enc = Lossy
+	case document.LosslessCompression:
+		enc = Lossless
+	}
+	if enc == Lossy {
+		return imageEncoding{lossy: true}
+	}
+	pix := nrgba(img)
+	if enc == Auto && pdf.Opaque(img) && looksLikePhoto(pix) {
+		return imageEncoding{lossy: true}
+	}
+	if _, ok := img.(*image.Gray); ok {
+		// A palette only saves space by using fewer bits per pixel.
+		if pal, _ := countColours(pix, 16); pal != nil {
+			return imageEncoding{palette: pal}
+		}
+		return imageEncoding{}
+	}
+	pal, quantized := palette(pix, opts.Quantize)
+	return imageEncoding{palette: pal, quantized: quantized}
+}
+
+func encodeImage(doc *pdf.Document, img image.Image, enc imageEncoding, opts Options) (*pdf.Stream, error) {
+	switch {
+	case enc.bilevel:
+		return doc.CCITTImage(bilevel.Threshold(img)), nil
+	case enc.lossy:
+		return doc.JPEGImage(img, quality(opts))
+	case enc.palette != nil:
+		return doc.IndexedImage(nrgba(img), enc.palette), nil
+	}
+	return doc.Image(img), nil
+}
+
+func quality(opts Options) int {
+	if opts.Quality <= 0 {
+		return jpeg.DefaultQuality
+	}
+	return opts.Quality
+}
+
+// Describes how the image of page is compressed when exported with opts,
+// e.g. "JPEG, embedded as is". Returns an empty string for other pages.
+func DescribeCompression(page document.Page, opts Options) (string, error) {
+	if page.Kind != document.ImagePage {
+		return "", nil
+	}
+	xobj, img, err := prepareImage(opts.PageSize.Convert(p4p.Point), page, opts)
+	if err != nil {
+		return "", err
+	}
+	if xobj != nil {
+		return "JPEG, embedded as is", nil
+	}
+	var b bytes.Buffer
+	enc := chooseEncoding(page, img, opts)
+	switch {
+	case enc.bilevel:
+		b.WriteString("Black and white, CCITT Group 4")
+	case enc.lossy:
+		fmt.Fprintf(&b, "JPEG, quality %v", quality(opts))
+	case enc.quantized:
+		fmt.Fprintf(&b, "Lossless, reduced to %v colours", len(enc.palette))
+	case enc.palette != nil:
+		fmt.Fprintf(&b, "Lossless, %v colours", len(enc.palette))
+	default:
+		b.WriteString("Lossless")
+	}
+	if page.Colours(opts.ColourMode) == document.Grayscale {
+		b.WriteString(", grayscale")
+	}
+	if page.Compression != document.DefaultCompression && !enc.bilevel {
+		b.WriteString(", set for this page")
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,110 @@
+package export
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+// Returns a w by h image with the colour of each pixel from at.
+func genImage(w, h int, at func(x, y int) color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA(x, y, at(x, y))
+		}
+	}
+	return img
+}
+
+func TestLooksLikePhoto(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	noise := func(v int) uint8 {
+		return uint8(max(0, min(255, v+rnd.Intn(9)-4)))
+	}
+	tests := []struct {
+		name string
+		img  *image.NRGBA
+		want bool
+	}{
+		{"gradient with noise", genImage(640, 480, func(x, y int) color.NRGBA {
+			return color.NRGBA{noise(x * 255 / 640), noise(y * 255 / 480), noise(128), 0xff}
+		}), true},
+		// Flat areas with hard edges, like windows and text.
+		{"screenshot", genImage(640, 480, func(x, y int) color.NRGBA {
+			switch {
+			case y < 30:
+				return color.NRGBA{40, 40, 40, 0xff}
+			case x < 150:
+				return color.NRGBA{230, 230, 235, 0xff}
+			case (x/6)%3 == 0 && (y/12)%2 == 0:
+				return color.NRGBA{0, 0, 0, 0xff}
+			}
+			return color.NRGBA{255, 255, 255, 0xff}
+		}), false},
+		{"single colour", genImage(100, 100, func(x, y int) color.NRGBA {
+			return color.NRGBA{200, 100, 50, 0xff}
+		}), false},
+		{"one pixel", genImage(1, 1, func(x, y int) color.NRGBA {
+			return color.NRGBA{200, 100, 50, 0xff}
+		}), false},
+	}
+	for _, tt := range tests {
+		if got := looksLikePhoto(tt.img); got != tt.want {
+			t.Errorf("%v: looksLikePhoto = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPalette(t *testing.T) {
+	// Colour i of n distinct ones.
+	colour := func(i int) color.NRGBA {
+		return color.NRGBA{uint8(i), uint8(i >> 8), 0, 0xff}
+	}
+	few := genImage(64, 64, func(x, y int) color.NRGBA {
+		return colour(x % 5)
+	})
+	// 256 colours cover most pixels, another 200 the rest.
+	antialiased := genImage(256, 256, func(x, y int) color.NRGBA {
+		if y == 0 && x < 200 {
+			return colour(256 + x)
+		}
+		return colour(x)
+	})
+	many := genImage(256, 256, func(x, y int) color.NRGBA {
+		return colour(x + 256*(y%64))
+	})
+	tests := []struct {
+		name          string
+		img           *image.NRGBA
+		quantize      bool
+		wantLen       int
+		wantQuantized bool
+	}{
+		{"few colours", few, false, 5, false},
+		{"few colours, quantize", few, true, 5, false},
+		{"anti-aliased", antialiased, false, 0, false},
+		{"anti-aliased, quantize", antialiased, true, 256, true},
+		{"many colours, quantize", many, true, 0, false},
+	}
+	for _, tt := range tests {
+		pal, quantized := palette(tt.img, tt.quantize)
+		if len(pal) != tt.wantLen || quantized != tt.wantQuantized {
+			t.Errorf("%v: got %v colours, quantized %v; want %v, %v",
+				tt.name, len(pal), quantized, tt.wantLen, tt.wantQuantized)
+		}
+	}
+
+	// The most frequent colours come first.
+	img := genImage(10, 1, func(x, y int) color.NRGBA {
+		return colour(min(x, 2))
+	})
+	pal, counts := countColours(img, 3)
+	if len(pal) != 3 || pal[0] != colour(2) || counts[0] != 8 {
+		t.Errorf("countColours = %v, %v; want %v first with 8 pixels", pal, counts, colour(2))
+	}
+	if pal, _ := countColours(img, 2); pal != nil {
+		t.Errorf("countColours over the limit = %v, want nil", pal)
+	}
+}
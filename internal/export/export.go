@@ -0,0 +1,363 @@
+// Package export writes the selected pages to a PDF file.
+package export
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+
+	p4p "github.com/pic4pdf/lib-p4p"
+	xdraw "golang.org/x/image/draw"
+
+	"github.com/pic4pdf/pic4pdf/internal/archive"
+	"github.com/pic4pdf/pic4pdf/internal/bilevel"
+	"github.com/pic4pdf/pic4pdf/internal/document"
+	"github.com/pic4pdf/pic4pdf/internal/imgload"
+	"github.com/pic4pdf/pic4pdf/internal/paste"
+	"github.com/pic4pdf/pic4pdf/internal/pdf"
+	"github.com/pic4pdf/pic4pdf/internal/raw"
+	"github.com/pic4pdf/pic4pdf/internal/textpage"
+)
+
+type Options struct {
+	PageSize p4p.PageSize
+	Image    p4p.ImageOptions
+	// If greater than 1, blank pages are added to the end until the page
+	// count is a multiple of PadTo.
+	PadTo int
+	// Margin of text pages in points.
+	Margin float64
+	// Adds a bookmark for each page, named after its file, below the
+	// bookmark of its section.
+	PageBookmarks bool
+	Metadata      Metadata
+	// Images with a higher resolution on the page are downsampled. 0 means
+	// no limit.
+	MaxDPI float64
+	// Quality of lossy encoding, 1 to 100. 0 means jpeg.DefaultQuality.
+	Quality int
+	// Encoding of photos (JPEG and RAW files) and of other images (e.g.
+	// screenshots). JPEG files are embedded as they are if possible either
+	// way, unless ReencodeJPEGs is set.
+	PhotoEncoding Encoding
+	OtherEncoding Encoding
+	// Re-encodes JPEG files as JPEG with Quality instead of embedding them
+	// as they are, so that lowering Quality makes them smaller. Set by
+	// FitSize.
+	ReencodeJPEGs bool
+	// Colour mode of pages which don't set their own.
+	ColourMode document.ColourMode
+	// Reduce images encoded losslessly to 256 colours if nearly all of
+	// their pixels have one of them, as in screenshots with anti-aliased
+	// text.
+	Quantize bool
+	// If set, the PDF is encrypted.
+	Encryption *pdf.Encryption
+	// Writes a PDF/A-2b file: images with transparency are drawn onto
+	// white and CMYK JPEGs are converted to RGB. Write returns a *PDFAError
+	// if the file still wouldn't comply, e.g. because it is encrypted.
+	PDFA bool
+	// If set, the PDF is signed.
+	Signature *Signature
+}
+
+// Reasons why a PDF/A file couldn't be written.
+type PDFAError struct {
+	Reasons []string
+}
+
+func (e *PDFAError) Error() string {
+	return "the PDF can't be made PDF/A-2b compliant: " + strings.Join(e.Reasons, "; ")
+}
+
+type Encoding int
+
+const (
+	// JPEG (DCTDecode).
+	Lossy Encoding = iota
+	// FlateDecode, with a palette for images with few colours.
+	Lossless
+	// Lossless for screenshots, line art and images with transparency and
+	// lossy for photos, judging by the image content.
+	Auto
+)
+
+// Returns pages with blank pages added as requested by padTo. Section
+// headers are not counted.
+func pad(pages []document.Page, padTo int) []document.Page {
+	count := len(document.Printed(pages))
+	if padTo <= 1 || count%padTo == 0 {
+		return pages
+	}
+	n := padTo - count%padTo
+	res := make([]document.Page, len(pages), len(pages)+n)
+	copy(res, pages)
+	for i := 0; i < n; i++ {
+		res = append(res, document.Page{Kind: document.BlankPage})
+	}
+	return res
+}
+
+// Returns the bookmark name of an image or text page.
+func bookmarkName(page document.Page) string {
+	if page.Kind == document.TextPage {
+		if name, _, _ := strings.Cut(strings.TrimSpace(page.Text.Heading), "\n"); name != "" {
+			return name
+		}
+		return "Text Page"
+	}
+	if name, ok := paste.Name(page.Path); ok {
+		return name
+	}
+	name := filepath.Base(page.Path)
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// Writes a PDF with the given pages to w. Each titled section becomes a
+// top-level bookmark pointing to its first page.
+func Write(w io.Writer, pages []document.Page, opts Options) error {
+	return write(context.Background(), w, pages, opts)
+}
+
+func write(ctx context.Context, w io.Writer, pages []document.Page, opts Options) error {
+	ps := opts.PageSize.Convert(p4p.Point)
+	doc := pdf.New()
+	doc.Encryption = opts.Encryption
+	now := time.Now()
+	setMetadata(doc, opts.Metadata, now, opts.PDFA)
+	if opts.PDFA {
+		doc.Catalog["OutputIntents"] = pdf.Array{pdf.Dict{
+			"Type":                      pdf.Name("OutputIntent"),
+			"S":                         pdf.Name("GTS_PDFA1"),
+			"OutputConditionIdentifier": pdf.String("sRGB IEC61966-2.1"),
+			"Info":                      pdf.String("sRGB IEC61966-2.1"),
+			"DestOutputProfile":         doc.Add(pdf.Flate(pdf.Dict{"N": 3}, pdf.SRGBProfile())),
+		}}
+	}
+	var regular, bold *pdf.Font
+	// Bookmarks are added once the next page is known.
+	var section *pdf.Outline
+	var sectionTitle string
+	var refs []pdf.Ref
+	for _, page := range pad(pages, opts.PadTo) {
+		if page.Kind == document.SectionStart {
+			sectionTitle = page.Title
+			section = nil
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		pg := pdf.NewPage(ps.W, ps.H)
+		if page.Kind != document.ImagePage && page.Background.A != 0 {
+			pg.FillRect(0, 0, ps.W, ps.H, page.Colour())
+		}
+		switch page.Kind {
+		case document.TextPage:
+			if regular == nil {
+				var err error
+				if regular, err = doc.AddFont(textpage.RegularTTF); err != nil {
+					return err
+				}
+				if bold, err = doc.AddFont(textpage.BoldTTF); err != nil {
+					return err
+				}
+			}
+			lines, fits := textpage.Layout(page.Text, ps.W, ps.H, opts.Margin)
+			if !fits {
+				return fmt.Errorf("the text of page %v doesn't fit on the page; shorten it or make the font smaller", len(refs)+1)
+			}
+			if missing := textpage.Missing(page.Text); len(missing) > 0 {
+				return fmt.Errorf("the text of page %v uses characters the font lacks: %q", len(refs)+1, string(missing))
+			}
+			for _, l := range lines {
+				f := regular
+				if l.Bold {
+					f = bold
+				}
+				pg.Text(f, l.Size, l.X, l.Y, l.Text)
+			}
+		case document.ImagePage:
+			if err := addImage(doc, pg, page, opts); err != nil {
+				return err
+			}
+		}
+		ref := doc.AddPage(pg)
+		refs = append(refs, ref)
+
+		if section == nil && sectionTitle != "" {
+			section = &pdf.Outline{Title: sectionTitle, Page: ref}
+			doc.Outlines = append(doc.Outlines, section)
+		}
+		if opts.PageBookmarks && page.Kind != document.BlankPage {
+			o := &pdf.Outline{Title: bookmarkName(page), Page: ref}
+			if section != nil {
+				section.Children = append(section.Children, o)
+			} else {
+				doc.Outlines = append(doc.Outlines, o)
+			}
+		}
+	}
+	if opts.Signature != nil {
+		if regular == nil && opts.Signature.StampPage != 0 {
+			var err error
+			if regular, err = doc.AddFont(textpage.RegularTTF); err != nil {
+				return err
+			}
+		}
+		if err := setSignature(doc, refs, ps, opts.Signature, now, regular); err != nil {
+			return err
+		}
+	}
+	if opts.PDFA {
+		if reasons := doc.CheckPDFA2B(); len(reasons) > 0 {
+			return &PDFAError{Reasons: reasons}
+		}
+	}
+	_, err := doc.WriteTo(w)
+	return err
+}
+
+// Places the image of page on pg.
+func addImage(doc *pdf.Document, pg *pdf.Page, page document.Page, opts Options) error {
+	ps := pageSize(pg)
+	xobj, img, err := prepareImage(ps, page, opts)
+	if err != nil {
+		return err
+	}
+	var w, h int
+	if img != nil {
+		w, h = img.Bounds().Dx(), img.Bounds().Dy()
+		if xobj, err = encodeImage(doc, img, chooseEncoding(page, img, opts), opts); err != nil {
+			return err
+		}
+	} else {
+		w, h = xobj.Dict["Width"].(int), xobj.Dict["Height"].(int)
+	}
+	// Parts outside the page, as in Fill mode, are clipped.
+	x, y, pw, ph, _, _, _, _, _ := p4p.Render(ps, p4p.Point, w, h, opts.Image)
+	pg.DrawImage(doc.Add(xobj), x, y, pw, ph)
+	return nil
+}
+
+func pageSize(pg *pdf.Page) p4p.PageSize {
+	return p4p.PageSize{W: pg.W, H: pg.H, Unit: p4p.Point}
+}
+
+// Reports whether the image at path is a photo rather than e.g. a
+// screenshot, judging by its format.
+func isPhoto(path string) bool {
+	return isJPEG(path) || raw.IsRaw(path)
+}
+
+func isJPEG(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".jpg" || ext == ".jpeg"
+}
+
+// Returns the size in pixels an image of w by h pixels is downsampled to
+// on a page of size ps, which is w and h if its resolution is within
+// opts.MaxDPI.
+func targetSize(ps p4p.PageSize, w, h int, opts Options) (int, int) {
+	if opts.MaxDPI <= 0 {
+		return w, h
+	}
+	_, _, pw, ph, _, _, _, _, _ := p4p.Render(ps, p4p.Point, w, h, opts.Image)
+	maxW := int(math.Round(pw / float64(p4p.Inch) * opts.MaxDPI))
+	maxH := int(math.Round(ph / float64(p4p.Inch) * opts.MaxDPI))
+	if w <= maxW || h <= maxH {
+		return w, h
+	}
+	return max(maxW, 1), max(maxH, 1)
+}
+
+// Returns either the image XObject of an unrotated JPEG file within
+// opts.MaxDPI and in the colour mode of page, which is embedded as it is
+// without decoding unless opts.ReencodeJPEGs is set, or the image of page downsampled and converted to
+// grayscale or, for PDF/A, made opaque as needed for encoding. ps is in
+// points.
+func prepareImage(ps p4p.PageSize, page document.Page, opts Options) (*pdf.Stream, image.Image, error) {
+	var img image.Image
+	mode := page.Colours(opts.ColourMode)
+	if page.Rotation == 0 && mode != document.BlackAndWhite && isJPEG(page.Path) {
+		data, err := readFile(page.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+		s, info, err := pdf.JPEG(data)
+		asIs := err == nil && (mode == document.KeepColours || info.Components == 1)
+		// CMYK doesn't match the sRGB output intent of PDF/A files.
+		if asIs && !opts.ReencodeJPEGs && !(opts.PDFA && info.Components == 4) {
+			w, h := targetSize(ps, info.Width, info.Height, opts)
+			if w == info.Width && h == info.Height {
+				return s, nil, nil
+			}
+		}
+		// Decode the data already read. This also reports invalid files.
+		if img, _, err = image.Decode(bytes.NewReader(data)); err != nil {
+			return nil, nil, fmt.Errorf("invalid image '%v': %w", filepath.Base(page.Path), err)
+		}
+	} else {
+		var err error
+		if img, err = imgload.LoadPage(page); err != nil {
+			return nil, nil, err
+		}
+	}
+	b := img.Bounds()
+	if w, h := targetSize(ps, b.Dx(), b.Dy(), opts); w != b.Dx() || h != b.Dy() {
+		var dst draw.Image
+		if pdf.Opaque(img) {
+			dst = image.NewRGBA(image.Rect(0, 0, w, h))
+		} else {
+			dst = image.NewNRGBA(image.Rect(0, 0, w, h))
+		}
+		xdraw.BiLinear.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+		img = dst
+	}
+	if mode == document.Grayscale {
+		img = bilevel.Gray(img)
+	} else if opts.PDFA && !pdf.Opaque(img) {
+		img = flatten(img)
+	}
+	return nil, img, nil
+}
+
+// Returns img drawn onto white.
+func flatten(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Over)
+	return dst
+}
+
+func readFile(path string) ([]byte, error) {
+	f, err := archive.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+type countWriter int64
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	*c += countWriter(len(p))
+	return len(p), nil
+}
+
+// Returns the size of the PDF Write would write. Returns ctx.Err() if ctx
+// is cancelled before.
+func Size(ctx context.Context, pages []document.Page, opts Options) (int64, error) {
+	var c countWriter
+	err := write(ctx, &c, pages, opts)
+	return int64(c), err
+}
@@ -0,0 +1,113 @@
+package export
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	p4p "github.com/pic4pdf/lib-p4p"
+
+	"github.com/pic4pdf/pic4pdf/internal/document"
+	"github.com/pic4pdf/pic4pdf/internal/pdf"
+)
+
+func TestWriteText(t *testing.T) {
+	tests := []struct {
+		text    document.Text
+		wantErr string
+	}{
+		{document.Text{Heading: "Title", Body: "Text"}, ""},
+		{document.Text{Body: strings.Repeat("Long text. ", 1000)}, "doesn't fit"},
+		{document.Text{Heading: "日本"}, `lacks: "日本"`},
+	}
+	for _, tt := range tests {
+		pages := []document.Page{{Kind: document.BlankPage}, {Kind: document.TextPage, Text: tt.text}}
+		err := Write(io.Discard, pages, Options{PageSize: p4p.A4(), Margin: 50})
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%+v: %v", tt.text, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), "page 2 ") || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%+v: got error %v, want one about page 2 containing %q", tt.text, err, tt.wantErr)
+		}
+	}
+}
+
+// Returns an 8 by 8 CMYK JPEG with an Adobe marker, all of whose samples
+// are 128.
+func cmykJPEG() []byte {
+	segment := func(marker byte, data ...byte) []byte {
+		n := len(data) + 2
+		return append([]byte{0xff, marker, byte(n >> 8), byte(n)}, data...)
+	}
+	b := []byte{0xff, 0xd8}
+	b = append(b, segment(0xee, 'A', 'd', 'o', 'b', 'e', 0, 100, 0, 0, 0, 0, 0)...)
+	b = append(b, segment(0xdb, append([]byte{0}, bytes.Repeat([]byte{1}, 64)...)...)...)
+	b = append(b, segment(0xc0, 8, 0, 8, 0, 8, 4, 1, 0x11, 0, 2, 0x11, 0, 3, 0x11, 0, 4, 0x11, 0)...)
+	// DC and AC tables with a 1-bit code for 0, so that all blocks are
+	// zero bits.
+	for _, class := range []byte{0x00, 0x10} {
+		b = append(b, segment(0xc4, append(append([]byte{class, 1}, make([]byte, 15)...), 0)...)...)
+	}
+	b = append(b, segment(0xda, 4, 1, 0, 2, 0, 3, 0, 4, 0, 0, 63, 0)...)
+	return append(b, 0, 0xff, 0xd9)
+}
+
+func TestWritePDFA(t *testing.T) {
+	dir := t.TempDir()
+	transparent := filepath.Join(dir, "transparent.png")
+	f, err := os.Create(transparent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	img.SetNRGBA(1, 1, color.NRGBA{R: 0xff, A: 0x80})
+	err = png.Encode(f, img)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmyk := filepath.Join(dir, "cmyk.jpg")
+	if err := os.WriteFile(cmyk, cmykJPEG(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		// Written to the PDF unless it is PDF/A.
+		unlessPDFA string
+	}{
+		{transparent, "/SMask"},
+		{cmyk, "/DeviceCMYK"},
+	}
+	for _, tt := range tests {
+		for _, pdfa := range []bool{false, true} {
+			var buf bytes.Buffer
+			pages := []document.Page{{Path: tt.path}}
+			if err := Write(&buf, pages, Options{PageSize: p4p.A4(), PDFA: pdfa}); err != nil {
+				t.Errorf("%v, PDF/A %v: %v", filepath.Base(tt.path), pdfa, err)
+				continue
+			}
+			if got := bytes.Contains(buf.Bytes(), []byte(tt.unlessPDFA)); got == pdfa {
+				t.Errorf("%v, PDF/A %v: has %v %v", filepath.Base(tt.path), pdfa, tt.unlessPDFA, got)
+			}
+		}
+	}
+
+	opts := Options{PageSize: p4p.A4(), PDFA: true, Encryption: &pdf.Encryption{UserPassword: "secret"}}
+	var pdfaErr *PDFAError
+	if err := Write(io.Discard, []document.Page{{Kind: document.BlankPage}}, opts); !errors.As(err, &pdfaErr) ||
+		!slices.Equal(pdfaErr.Reasons, []string{"it is encrypted"}) {
+		t.Errorf("encrypted: got error %v, want a *PDFAError because it is encrypted", err)
+	}
+}
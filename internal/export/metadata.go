@@ -0,0 +1,142 @@
+package export
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"time"
+
+	"github.com/pic4pdf/pic4pdf/internal/document"
+	"github.com/pic4pdf/pic4pdf/internal/exifdate"
+	"github.com/pic4pdf/pic4pdf/internal/pdf"
+)
+
+// Name written as the PDF Producer.
+const producer = "pic4pdf"
+
+// Document information written to the PDF Info dictionary and XMP metadata.
+// Empty fields are left out.
+type Metadata struct {
+	Title   string
+	Author  string
+	Subject string
+	// Comma-separated.
+	Keywords string
+	// Application which created the original content.
+	Creator string
+	// Zero means the time of export.
+	CreationDate time.Time
+}
+
+// Returns the earliest EXIF date of the image pages, or the zero time if
+// none has one.
+func EarliestExifDate(pages []document.Page) time.Time {
+	var res time.Time
+	for _, p := range pages {
+		if p.Kind != document.ImagePage {
+			continue
+		}
+		t, err := exifdate.Get(p.Path)
+		if err == nil && !t.IsZero() && (res.IsZero() || t.Before(res)) {
+			res = t
+		}
+	}
+	return res
+}
+
+// Splits comma-separated keywords, dropping empty ones.
+func splitKeywords(s string) []string {
+	var res []string
+	for _, k := range strings.Split(s, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			res = append(res, k)
+		}
+	}
+	return res
+}
+
+// Sets the Info dictionary and XMP metadata, identifying the file as PDF/A-2b
+// if pdfa is set. Dates are written in UTC, so both agree.
+func setMetadata(doc *pdf.Document, m Metadata, now time.Time, pdfa bool) {
+	created := m.CreationDate
+	if created.IsZero() {
+		created = now
+	}
+	created, now = created.UTC(), now.UTC()
+	set := func(key pdf.Name, s string) {
+		if s != "" {
+			doc.Info[key] = pdf.String(s)
+		}
+	}
+	set("Producer", producer)
+	set("Title", m.Title)
+	set("Author", m.Author)
+	set("Subject", m.Subject)
+	set("Keywords", m.Keywords)
+	set("Creator", m.Creator)
+	const infoDate = "D:20060102150405Z"
+	set("CreationDate", created.Format(infoDate))
+	set("ModDate", now.Format(infoDate))
+	// Metadata streams stay uncompressed, so tools can find them.
+	doc.Catalog["Metadata"] = doc.Add(&pdf.Stream{
+		Dict: pdf.Dict{"Type": pdf.Name("Metadata"), "Subtype": pdf.Name("XML")},
+		Data: xmpPacket(m, created, now, pdfa),
+	})
+}
+
+// Returns an XMP packet matching the Info dictionary written by setMetadata.
+func xmpPacket(m Metadata, created, modified time.Time, pdfa bool) []byte {
+	var b bytes.Buffer
+	esc := func(s string) string {
+		var e bytes.Buffer
+		xml.EscapeText(&e, []byte(s))
+		return e.String()
+	}
+	langAlt := func(tag, s string) {
+		if s != "" {
+			b.WriteString("<" + tag + "><rdf:Alt><rdf:li xml:lang=\"x-default\">" + esc(s) + "</rdf:li></rdf:Alt></" + tag + ">\n")
+		}
+	}
+	simple := func(tag, s string) {
+		if s != "" {
+			b.WriteString("<" + tag + ">" + esc(s) + "</" + tag + ">\n")
+		}
+	}
+	const dateFormat = "2006-01-02T15:04:05Z"
+
+	b.WriteString("<?xpacket begin=\"\uFEFF\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.WriteString("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n")
+	b.WriteString("<rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\">\n")
+	b.WriteString("<rdf:Description rdf:about=\"\"" +
+		" xmlns:dc=\"http://purl.org/dc/elements/1.1/\"" +
+		" xmlns:xmp=\"http://ns.adobe.com/xap/1.0/\"" +
+		" xmlns:pdf=\"http://ns.adobe.com/pdf/1.3/\">\n")
+	b.WriteString("<dc:format>application/pdf</dc:format>\n")
+	langAlt("dc:title", m.Title)
+	if m.Author != "" {
+		b.WriteString("<dc:creator><rdf:Seq><rdf:li>" + esc(m.Author) + "</rdf:li></rdf:Seq></dc:creator>\n")
+	}
+	langAlt("dc:description", m.Subject)
+	if kws := splitKeywords(m.Keywords); len(kws) > 0 {
+		b.WriteString("<dc:subject><rdf:Bag>")
+		for _, k := range kws {
+			b.WriteString("<rdf:li>" + esc(k) + "</rdf:li>")
+		}
+		b.WriteString("</rdf:Bag></dc:subject>\n")
+	}
+	simple("pdf:Keywords", m.Keywords)
+	simple("pdf:Producer", producer)
+	simple("xmp:CreatorTool", m.Creator)
+	simple("xmp:CreateDate", created.Format(dateFormat))
+	simple("xmp:ModifyDate", modified.Format(dateFormat))
+	simple("xmp:MetadataDate", modified.Format(dateFormat))
+	if pdfa {
+		b.WriteString("</rdf:Description>\n")
+		b.WriteString("<rdf:Description rdf:about=\"\" xmlns:pdfaid=\"http://www.aiim.org/pdfa/ns/id/\">\n")
+		simple("pdfaid:part", "2")
+		simple("pdfaid:conformance", "B")
+	}
+	b.WriteString("</rdf:Description>\n</rdf:RDF>\n</x:xmpmeta>\n")
+	b.WriteString("<?xpacket end=\"w\"?>")
+	return b.Bytes()
+}
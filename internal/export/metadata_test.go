@@ -0,0 +1,134 @@
+package export
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/pic4pdf/pic4pdf/internal/pdf"
+)
+
+// The parts of an XMP packet written by xmpPacket.
+type xmpMeta struct {
+	Description struct {
+		Format      string   `xml:"format"`
+		Title       string   `xml:"title>Alt>li"`
+		Creator     []string `xml:"creator>Seq>li"`
+		Description string   `xml:"description>Alt>li"`
+		Subject     []string `xml:"subject>Bag>li"`
+		Keywords    string   `xml:"Keywords"`
+		Producer    string   `xml:"Producer"`
+		CreatorTool string   `xml:"CreatorTool"`
+		CreateDate  string   `xml:"CreateDate"`
+		ModifyDate  string   `xml:"ModifyDate"`
+		Part        string   `xml:"part"`
+		Conformance string   `xml:"conformance"`
+	} `xml:"RDF>Description"`
+}
+
+// Returns how String(s) is written in a PDF file.
+func pdfString(s string) string {
+	for _, c := range s {
+		if c < 0x20 || c > 0x7e {
+			var b strings.Builder
+			b.WriteString("<FEFF")
+			for _, u := range utf16.Encode([]rune(s)) {
+				fmt.Fprintf(&b, "%04X", u)
+			}
+			b.WriteString(">")
+			return b.String()
+		}
+	}
+	r := strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`)
+	return "(" + r.Replace(s) + ")"
+}
+
+func TestSetMetadata(t *testing.T) {
+	m := Metadata{
+		Title:    "Fotos & <Ideen> – Überblick 日本",
+		Author:   "Tom & Jerry <tj@example.com>",
+		Subject:  "Ferien (2023)",
+		Keywords: "Strand, , Berge & Seen,",
+		Creator:  "Scanner <1>",
+		// Written in UTC.
+		CreationDate: time.Date(2023, 7, 1, 14, 30, 0, 0, time.FixedZone("CEST", 2*60*60)),
+	}
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, pdfa := range []bool{false, true} {
+		doc := pdf.New()
+		setMetadata(doc, m, now, pdfa)
+		var buf bytes.Buffer
+		if _, err := doc.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		out := buf.String()
+
+		for key, s := range map[string]string{
+			"Title":        m.Title,
+			"Author":       m.Author,
+			"Subject":      m.Subject,
+			"Keywords":     m.Keywords,
+			"Creator":      m.Creator,
+			"Producer":     producer,
+			"CreationDate": "D:20230701123000Z",
+			"ModDate":      "D:20240102030405Z",
+		} {
+			if want := "/" + key + " " + pdfString(s); !strings.Contains(out, want) {
+				t.Errorf("Info lacks %v", want)
+			}
+		}
+		if !strings.Contains(out, "/Title <FEFF") {
+			t.Errorf("non-ASCII title not written as UTF-16")
+		}
+
+		start := strings.Index(out, "<?xpacket begin")
+		end := strings.Index(out, "<?xpacket end=\"w\"?>")
+		if start < 0 || end < 0 {
+			t.Fatalf("no XMP packet in %q", out)
+		}
+		var x xmpMeta
+		if err := xml.Unmarshal([]byte(out[start:end]), &x); err != nil {
+			t.Fatalf("invalid XMP packet: %v", err)
+		}
+		d := x.Description
+		if d.Format != "application/pdf" || d.Title != m.Title || !slices.Equal(d.Creator, []string{m.Author}) ||
+			d.Description != m.Subject || d.Keywords != m.Keywords || d.Producer != producer || d.CreatorTool != m.Creator {
+			t.Errorf("XMP %+v doesn't match %+v", d, m)
+		}
+		if want := []string{"Strand", "Berge & Seen"}; !slices.Equal(d.Subject, want) {
+			t.Errorf("XMP subject %q, want %q", d.Subject, want)
+		}
+		if d.CreateDate != "2023-07-01T12:30:00Z" || d.ModifyDate != "2024-01-02T03:04:05Z" {
+			t.Errorf("XMP dates %v and %v", d.CreateDate, d.ModifyDate)
+		}
+		if part, conf := d.Part, d.Conformance; pdfa && (part != "2" || conf != "B") || !pdfa && (part != "" || conf != "") {
+			t.Errorf("PDF/A %v: identified as part %q conformance %q", pdfa, part, conf)
+		}
+	}
+}
+
+func TestSetMetadataEmpty(t *testing.T) {
+	doc := pdf.New()
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	setMetadata(doc, Metadata{}, now, false)
+	for _, key := range []pdf.Name{"Title", "Author", "Subject", "Keywords", "Creator"} {
+		if v, ok := doc.Info[key]; ok {
+			t.Errorf("Info has %v %v", key, v)
+		}
+	}
+	if doc.Info["CreationDate"] != pdf.String("D:20240102030405Z") {
+		t.Errorf("creation date %v, want the time of export", doc.Info["CreationDate"])
+	}
+	var x xmpMeta
+	if err := xml.Unmarshal(xmpPacket(Metadata{}, now, now, false), &x); err != nil {
+		t.Fatalf("invalid XMP packet: %v", err)
+	}
+	if d := x.Description; d.Title != "" || d.Creator != nil || d.Subject != nil || d.CreateDate != "2024-01-02T03:04:05Z" {
+		t.Errorf("XMP %+v without metadata", d)
+	}
+}
@@ -0,0 +1,73 @@
+package export
+
+import (
+	"fmt"
+	"image/color"
+	"time"
+
+	p4p "github.com/pic4pdf/lib-p4p"
+
+	"github.com/pic4pdf/pic4pdf/internal/pdf"
+	"github.com/pic4pdf/pic4pdf/internal/sign"
+)
+
+// Signs the PDF, optionally with a stamp showing the signer and signing
+// time.
+type Signature struct {
+	Signer *sign.Signer
+	// Number of the page with the stamp, counting from 1 or, if negative,
+	// from the end (-1 is the last page). 0 means no stamp.
+	StampPage int
+}
+
+// Size of the stamp and its distance from the bottom right of the page in
+// points.
+const (
+	stampW, stampH = 200, 56
+	stampMargin    = 24
+)
+
+// Returns the name of the signer.
+func (s *Signature) signerName() string {
+	subject := s.Signer.Chain[0].Subject
+	if subject.CommonName != "" {
+		return subject.CommonName
+	}
+	return subject.String()
+}
+
+// Sets up signing doc, whose pages of size ps (in points) are given, at t.
+// font is used for the stamp.
+func setSignature(doc *pdf.Document, pages []pdf.Ref, ps p4p.PageSize, s *Signature, t time.Time, font *pdf.Font) error {
+	if len(pages) == 0 {
+		return fmt.Errorf("there are no pages to sign")
+	}
+	sig := &pdf.Signature{
+		Name: s.signerName(),
+		Time: t,
+		Sign: s.Signer.Sign,
+		Size: s.Signer.MaxSize(),
+	}
+	sig.Page = pages[0]
+	if s.StampPage != 0 {
+		n := s.StampPage
+		if n < 0 {
+			n += len(pages) + 1
+		}
+		if n < 1 || n > len(pages) {
+			return fmt.Errorf("page %v for the signature stamp doesn't exist, the PDF has %v pages", s.StampPage, len(pages))
+		}
+		sig.Page = pages[n-1]
+		stamp := pdf.NewPage(stampW, stampH)
+		stamp.FillRect(0, 0, stampW, stampH, color.NRGBA{R: 0x30, G: 0x50, B: 0x90, A: 0xff})
+		stamp.FillRect(1, 1, stampW-2, stampH-2, color.NRGBA{R: 0xee, G: 0xf2, B: 0xfa, A: 0xff})
+		stamp.Text(font, 8, 8, 15, "Digitally signed by")
+		stamp.Text(font, 11, 8, 31, sig.Name)
+		stamp.Text(font, 8, 8, 47, t.Format("2006-01-02 15:04:05 -07:00"))
+		sig.Appearance = doc.AddForm(stamp)
+		sig.X, sig.Y = ps.W-stampW-stampMargin, ps.H-stampH-stampMargin
+		sig.W, sig.H = stampW, stampH
+	}
+	doc.Signature = sig
+	return nil
+}
@@ -0,0 +1,92 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"image/jpeg"
+
+	"github.com/pic4pdf/pic4pdf/internal/document"
+)
+
+// Resolution limits tried in order when fitting a PDF into a target size.
+var targetDPIs = []float64{300, 200, 150, 100, 72}
+
+const (
+	// Lowest quality used before lowering the resolution further, so that
+	// text in scans stays readable.
+	readableQuality = 50
+	// Lowest quality used at the lowest resolution.
+	minQuality = 10
+)
+
+// Returned by FitSize if the PDF can't be made small enough.
+type TargetSizeError struct {
+	Target int64
+	// Size of the smallest PDF possible.
+	Closest int64
+}
+
+func (e *TargetSizeError) Error() string {
+	return fmt.Sprintf("the PDF can't be made smaller than %v bytes, target is %v bytes", e.Closest, e.Target)
+}
+
+// Returns opts with MaxDPI and Quality lowered so that the PDF has at most
+// target bytes, and the resulting size. The resolution is kept as high as
+// possible, lowering the quality first, for which JPEG files are re-encoded
+// rather than embedded as they are. If the target can't be met, it returns
+// the options giving the smallest PDF and a *TargetSizeError.
+func FitSize(ctx context.Context, pages []document.Page, opts Options, target int64) (Options, int64, error) {
+	if opts.Quality <= 0 {
+		opts.Quality = jpeg.DefaultQuality
+	}
+	n, err := Size(ctx, pages, opts)
+	if err != nil || n <= target {
+		return opts, n, err
+	}
+	dpis := []float64{opts.MaxDPI}
+	for _, dpi := range targetDPIs {
+		if opts.MaxDPI <= 0 || dpi < opts.MaxDPI {
+			dpis = append(dpis, dpi)
+		}
+	}
+	best, bestSize := opts, n
+	for i, dpi := range dpis {
+		floor := readableQuality
+		if i == len(dpis)-1 {
+			floor = minQuality
+		}
+		floor = min(floor, opts.Quality)
+		o := opts
+		o.MaxDPI = dpi
+		o.Quality = floor
+		o.ReencodeJPEGs = true
+		n, err := Size(ctx, pages, o)
+		if err != nil {
+			return o, n, err
+		}
+		if n < bestSize {
+			best, bestSize = o, n
+		}
+		if n > target {
+			continue
+		}
+		// Search the highest quality that fits.
+		lo, hi := floor, opts.Quality
+		fitSize := n
+		for lo < hi {
+			o.Quality = (lo + hi + 1) / 2
+			n, err := Size(ctx, pages, o)
+			if err != nil {
+				return o, n, err
+			}
+			if n <= target {
+				lo, fitSize = o.Quality, n
+			} else {
+				hi = o.Quality - 1
+			}
+		}
+		o.Quality = lo
+		return o, fitSize, nil
+	}
+	return best, bestSize, &TargetSizeError{Target: target, Closest: bestSize}
+}
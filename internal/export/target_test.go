@@ -0,0 +1,79 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	p4p "github.com/pic4pdf/lib-p4p"
+
+	"github.com/pic4pdf/pic4pdf/internal/document"
+)
+
+// Writes a photo-like JPEG file of w by h pixels with the given quality.
+func writePhoto(t *testing.T, path string, w, h, quality int) {
+	t.Helper()
+	rnd := rand.New(rand.NewSource(1))
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			n := uint8(rnd.Intn(32))
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(x*200/w) + n, G: uint8(y*200/h) + n, B: 100 + n, A: 0xff})
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := jpeg.Encode(f, img, &jpeg.Options{Quality: quality}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFitSizeJPEG(t *testing.T) {
+	dir := t.TempDir()
+	var pages []document.Page
+	for _, name := range []string{"a.jpg", "b.jpg"} {
+		path := filepath.Join(dir, name)
+		// About 120 DPI on A4.
+		writePhoto(t, path, 1000, 1300, 95)
+		pages = append(pages, document.Page{Path: path})
+	}
+	opts := Options{PageSize: p4p.A4(), Image: p4p.ImageOptions{Mode: p4p.Fit}, Quality: 95}
+	ctx := context.Background()
+	full, err := Size(ctx, pages, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target := full / 2
+	fitted, n, err := FitSize(ctx, pages, opts, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fitted.MaxDPI != 0 || fitted.Quality >= opts.Quality || !fitted.ReencodeJPEGs {
+		t.Errorf("fitting %v bytes into %v: got MaxDPI %v, quality %v, re-encoding %v; want the original resolution at a lower quality",
+			full, target, fitted.MaxDPI, fitted.Quality, fitted.ReencodeJPEGs)
+	}
+	if size, err := Size(ctx, pages, fitted); err != nil || size != n || n > target {
+		t.Errorf("fitting %v bytes into %v: got %v bytes, reported %v", full, target, size, n)
+	}
+
+	// Nothing changes if the PDF is small enough.
+	if fitted, n, err := FitSize(ctx, pages, opts, full); err != nil || n != full || fitted.ReencodeJPEGs {
+		t.Errorf("fitting %v bytes into as many: got %v bytes, %+v, %v", full, n, fitted, err)
+	}
+
+	var notMet *TargetSizeError
+	fitted, n, err = FitSize(ctx, pages, opts, 1000)
+	if !errors.As(err, &notMet) || notMet.Closest != n || fitted.MaxDPI != targetDPIs[len(targetDPIs)-1] || fitted.Quality != minQuality {
+		t.Errorf("fitting into 1000 bytes: got %v bytes, MaxDPI %v, quality %v, %v", n, fitted.MaxDPI, fitted.Quality, err)
+	}
+}
@@ -1,35 +1,133 @@
 package gui
 
 import (
+	"fmt"
+	"image/color"
+	"log"
+	"math"
 	"path/filepath"
 	"slices"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
+
+	"github.com/pic4pdf/pic4pdf/internal/archive"
+	"github.com/pic4pdf/pic4pdf/internal/document"
+	"github.com/pic4pdf/pic4pdf/internal/exifdate"
+	"github.com/pic4pdf/pic4pdf/internal/history"
+	"github.com/pic4pdf/pic4pdf/internal/natsort"
+	"github.com/pic4pdf/pic4pdf/internal/paste"
+	"github.com/pic4pdf/pic4pdf/internal/raw"
+	"github.com/pic4pdf/pic4pdf/internal/textpage"
 )
 
+// Number of steps that can be undone.
+const maxUndo = 100
+
+// Time the mouse must rest on a page before its tooltip is shown.
+const tooltipDelay = 700 * time.Millisecond
+
 type FileOverview struct {
 	widget.BaseWidget
 
+	undo         *widget.Button
+	redo         *widget.Button
 	moveDown     *widget.Button
 	moveUp       *widget.Button
 	moveDownFull *widget.Button
 	moveUpFull   *widget.Button
+	rotate       *widget.Button
+	duplicate    *widget.Button
+	remove       *widget.Button
+	paste        *widget.Button
+	sort         *widget.Button
+	duplex       *widget.Button
+	sections     *widget.Button
+	image        *widget.Button
+	insert       *widget.Button
 	list         *widget.List
+	dropMarker   *canvas.Rectangle
+	tooltip      *fyne.Container
+	tooltipLabel *widget.Label
 	obj          *fyne.Container
 
 	OnSelected   func(path string)
 	OnUnselected func(path string)
-	OnReorder    func()
+	// Called once per operation changing the page order.
+	OnReorder func()
+	// Called when the settings of pages (e.g. rotation) change.
+	OnPagesChanged func()
+	OnError        func(error)
+	// Runs fn on the goroutine which handles input events, to apply the
+	// results of work done in the background. fn is called directly if
+	// QueueEvent is nil.
+	QueueEvent func(fn func())
+	// Returns the tooltip of a page, e.g. how it is exported. It is called
+	// in a goroutine.
+	PageTooltip func(page document.Page) string
 
 	FileSelector *FileSelector
+	// Undo history of the pages. Other components can record their own
+	// changes (e.g. options) here too.
+	History *history.History
+
+	pages []*document.Page
+	// Indexes of the pages shown as rows, leaving out those in collapsed
+	// sections.
+	rows []int
+	// IDs of the SectionStarts of collapsed sections.
+	collapsed map[int]struct{}
+	// Rows selected in the list, which the toolbar actions apply to.
+	selected map[*document.Page]struct{}
+	// Where shift-selection extends from.
+	anchor *document.Page
+	// Number of pages in History referring to each pasted image.
+	pastedRefs map[string]int
+	// ID of the next page added.
+	nextID int
+	// Row the tooltip is shown or about to be shown for.
+	tooltipItem *pageItem
+	// Incremented when the tooltip is hidden, cancelling one about to be
+	// shown.
+	tooltipGen atomic.Int64
+}
 
-	paths []string
+// Returns the name a path is displayed as.
+func displayName(path string) string {
+	if name, ok := paste.Name(path); ok {
+		return name
+	}
+	return filepath.Base(path)
+}
+
+// Returns the name a page is displayed as.
+func pageName(page document.Page) string {
+	switch page.Kind {
+	case document.BlankPage:
+		return "Blank Page"
+	case document.TextPage:
+		if name, _, _ := strings.Cut(strings.TrimSpace(page.Text.Heading), "\n"); name != "" {
+			return name
+		}
+		return "Text Page"
+	case document.SectionStart:
+		if page.Title == "" {
+			return "No Section"
+		}
+		return page.Title
+	}
+	return displayName(page.Path)
 }
 
-// Sets fileSelector.OnSelected and OnUnselected!
+// Sets fileSelector.OnSelected, OnUnselected and History!
 // Plase use FileOverview.OnSelected and OnUnselected instead.
 func NewFileOverview(fileSelector *FileSelector) *FileOverview {
 	fl := &FileOverview{
@@ -41,9 +139,14 @@ func NewFileOverview(fileSelector *FileSelector) *FileOverview {
 
 func (fo *FileOverview) ExtendBaseWidget(w fyne.Widget) {
 	fo.BaseWidget.ExtendBaseWidget(w)
+	fo.selected = make(map[*document.Page]struct{})
+	fo.collapsed = make(map[int]struct{})
+	fo.pastedRefs = make(map[string]int)
+	fo.History = history.New(maxUndo)
+	fo.FileSelector.History = fo.History
 	fo.list = widget.NewList(
 		func() int {
-			return len(fo.paths)
+			return len(fo.rows)
 		}, func() fyne.CanvasObject {
 			item := newFileItem(
 				"PLACEHOLDER",
@@ -52,113 +155,194 @@ func (fo *FileOverview) ExtendBaseWidget(w fyne.Widget) {
 				nil,
 			)
 			item.LabelButton.Hide()
-			return item
+			pi := newPageItem(item)
+			pi.OnTapped = fo.tapRow
+			pi.OnDragged = fo.dragRow
+			pi.OnDragEnd = fo.dropRow
+			pi.OnHover = fo.hoverRow
+			pi.OnHoverEnd = fo.hideTooltip
+			return pi
 		}, func(id widget.ListItemID, obj fyne.CanvasObject) {
-			item := obj.(*FileItem)
-			item.Label.SetText(filepath.Base(fo.paths[id]))
-			item.LabelIcon.SetResource(theme.FileImageIcon())
+			pi := obj.(*pageItem)
+			pi.ID = id
+			item := pi.Item
+			if id >= len(fo.rows) {
+				return
+			}
+			page := fo.pages[fo.rows[id]]
+			isSection := page.Kind == document.SectionStart
+			item.Label.TextStyle.Bold = isSection
+			if isSection {
+				item.Label.SetText(fmt.Sprintf("%v (%v)", pageName(*page), fo.sectionSize(fo.rows[id])))
+			} else {
+				item.Label.SetText(pageName(*page))
+			}
+			item.IconButton.SetIcon(theme.ContentRemoveIcon())
 			item.IconButton.OnTapped = func() {
-				fo.FileSelector.Unselect(fo.paths[id])
+				fo.removePages("Remove Page", []*document.Page{page})
+			}
+			switch {
+			case isSection:
+				item.LabelIcon.SetResource(theme.FolderIcon())
+				// The button of a section header collapses it instead.
+				if _, ok := fo.collapsed[page.ID]; ok {
+					item.IconButton.SetIcon(theme.MenuExpandIcon())
+				} else {
+					item.IconButton.SetIcon(theme.MenuDropDownIcon())
+				}
+				item.IconButton.OnTapped = func() {
+					fo.ToggleCollapsed(page)
+				}
+			case page.Kind == document.BlankPage:
+				item.LabelIcon.SetResource(theme.FileIcon())
+			case page.Kind == document.TextPage:
+				item.LabelIcon.SetResource(theme.DocumentIcon())
+			case raw.IsRaw(page.Path):
+				item.LabelIcon.SetResource(theme.MediaPhotoIcon())
+			default:
+				item.LabelIcon.SetResource(theme.FileImageIcon())
 			}
+			if _, ok := fo.selected[page]; ok {
+				item.Overlay.Show()
+			} else {
+				item.Overlay.Hide()
+			}
+			item.Label.Refresh()
 		},
 	)
+	fo.dropMarker = canvas.NewRectangle(color.Transparent)
+	fo.dropMarker.Hide()
+	fo.tooltipLabel = widget.NewLabel("")
+	fo.tooltip = container.NewStack(canvas.NewRectangle(theme.OverlayBackgroundColor()), fo.tooltipLabel)
+	fo.tooltip.Hide()
 
-	var moveFileItem func(up bool, full bool)
-	fo.moveDown = widget.NewButtonWithIcon("", theme.MenuDropDownIcon(), func() { moveFileItem(false, false) })
-	fo.moveUp = widget.NewButtonWithIcon("", theme.MenuDropUpIcon(), func() { moveFileItem(true, false) })
-	fo.moveDownFull = widget.NewButtonWithIcon("", theme.MoveDownIcon(), func() { moveFileItem(false, true) })
-	fo.moveUpFull = widget.NewButtonWithIcon("", theme.MoveUpIcon(), func() { moveFileItem(true, true) })
-	selectedFileID := -1
-
-	refreshButtons := func() {
-		id := selectedFileID
-		if len(fo.paths) == 0 {
-			id = -1
-		}
-		if id == -1 || id == len(fo.paths)-1 {
-			fo.moveDown.Disable()
-			fo.moveDownFull.Disable()
-		} else {
-			fo.moveDown.Enable()
-			fo.moveDownFull.Enable()
-		}
-		if id == -1 || id == 0 {
-			fo.moveUp.Disable()
-			fo.moveUpFull.Disable()
-		} else {
-			fo.moveUp.Enable()
-			fo.moveUpFull.Enable()
+	fo.undo = widget.NewButtonWithIcon("", theme.ContentUndoIcon(), func() { fo.History.Undo() })
+	fo.redo = widget.NewButtonWithIcon("", theme.ContentRedoIcon(), func() { fo.History.Redo() })
+	fo.moveDown = widget.NewButtonWithIcon("", theme.MenuDropDownIcon(), func() { fo.moveSelected(false, false) })
+	fo.moveUp = widget.NewButtonWithIcon("", theme.MenuDropUpIcon(), func() { fo.moveSelected(true, false) })
+	fo.moveDownFull = widget.NewButtonWithIcon("", theme.MoveDownIcon(), func() { fo.moveSelected(false, true) })
+	fo.moveUpFull = widget.NewButtonWithIcon("", theme.MoveUpIcon(), func() { fo.moveSelected(true, true) })
+	fo.rotate = widget.NewButtonWithIcon("", theme.ViewRefreshIcon(), func() { fo.RotateSelected(90) })
+	fo.duplicate = widget.NewButtonWithIcon("", theme.ContentCopyIcon(), func() { fo.DuplicateSelected() })
+	fo.remove = widget.NewButtonWithIcon("", theme.DeleteIcon(), func() { fo.RemoveSelected() })
+	fo.paste = widget.NewButtonWithIcon("Paste", theme.ContentPasteIcon(), func() { fo.PasteImage() })
+	fo.sort = widget.NewButtonWithIcon("Sort", theme.MenuDropDownIcon(), nil)
+	fo.sort.IconPlacement = widget.ButtonIconTrailingText
+	fo.sort.OnTapped = func() {
+		item := func(label string, key PageSortKey, descending bool) *fyne.MenuItem {
+			return fyne.NewMenuItem(label, func() { fo.SortPages(key, descending) })
 		}
+		menu := fyne.NewMenu("",
+			item("Name", SortPagesByName, false),
+			item("Name (Descending)", SortPagesByName, true),
+			fyne.NewMenuItemSeparator(),
+			item("Date Modified", SortPagesByModified, false),
+			item("Date Modified (Descending)", SortPagesByModified, true),
+			fyne.NewMenuItemSeparator(),
+			item("Date Taken", SortPagesByDateTaken, false),
+			item("Date Taken (Descending)", SortPagesByDateTaken, true),
+		)
+		showMenuBelow(fo.sort, menu)
 	}
-
-	fo.list.OnSelected = func(id widget.ListItemID) {
-		selectedFileID = id
-		refreshButtons()
-	}
-
-	moveFileItem = func(up bool, full bool) {
-		id := selectedFileID
-		if id == -1 {
-			return
+	fo.History.OnChanged = fo.refreshButtons
+	fo.duplex = widget.NewButtonWithIcon("Duplex", theme.MenuDropDownIcon(), nil)
+	fo.duplex.IconPlacement = widget.ButtonIconTrailingText
+	fo.duplex.OnTapped = func() {
+		item := func(label string, reverse, blanks bool) *fyne.MenuItem {
+			return fyne.NewMenuItem(label, func() { fo.InterleaveDuplex(reverse, blanks) })
 		}
-		defer func() {
-			fo.list.Select(id)
-			fo.list.Refresh()
-			if fo.OnReorder != nil {
-				fo.OnReorder()
-			}
-		}()
-		for {
-			if up {
-				if id == 0 {
-					return
-				}
-				fo.paths[id], fo.paths[id-1] = fo.paths[id-1], fo.paths[id]
-				id = id - 1
-			} else {
-				if id == len(fo.paths)-1 {
-					return
-				}
-				fo.paths[id], fo.paths[id+1] = fo.paths[id+1], fo.paths[id]
-				id = id + 1
-			}
-			if !full {
-				return
+		menu := fyne.NewMenu("",
+			item("Interleave", false, false),
+			item("Interleave (Backs Reversed)", true, false),
+			fyne.NewMenuItemSeparator(),
+			item("Interleave with Blanks", false, true),
+			item("Interleave with Blanks (Backs Reversed)", true, true),
+		)
+		showMenuBelow(fo.duplex, menu)
+	}
+	fo.insert = widget.NewButtonWithIcon("Insert", theme.MenuDropDownIcon(), nil)
+	fo.insert.IconPlacement = widget.ButtonIconTrailingText
+	fo.insert.OnTapped = func() {
+		setBg := fyne.NewMenuItem("Background Colour...", func() {
+			fo.pickColour("Background Colour", func(c color.NRGBA) { fo.SetBackground(c) })
+		})
+		setBg.Disabled = !fo.backgroundSelected()
+		editText := fyne.NewMenuItem("Edit Text Page...", func() {
+			if page := fo.selectedTextPage(); page != nil {
+				fo.showTextDialog("Edit Text Page", page.Text, fo.SetText)
 			}
-		}
+		})
+		editText.Disabled = fo.selectedTextPage() == nil
+		menu := fyne.NewMenu("",
+			fyne.NewMenuItem("Blank Page", func() { fo.InsertBlank(color.NRGBA{}) }),
+			fyne.NewMenuItem("Separator Page...", func() {
+				fo.pickColour("Separator Page", func(c color.NRGBA) { fo.InsertBlank(c) })
+			}),
+			fyne.NewMenuItem("Text Page...", func() {
+				fo.showTextDialog("Insert Text Page", document.Text{
+					FontSize: textpage.DefaultFontSize,
+					Align:    document.AlignCenter,
+				}, fo.InsertText)
+			}),
+			fyne.NewMenuItemSeparator(),
+			editText,
+			setBg,
+		)
+		showMenuBelow(fo.insert, menu)
 	}
+	fo.sections = widget.NewButtonWithIcon("Sections", theme.MenuDropDownIcon(), nil)
+	fo.sections.IconPlacement = widget.ButtonIconTrailingText
+	fo.sections.OnTapped = fo.showSectionsMenu
+	fo.image = widget.NewButtonWithIcon("Image", theme.MenuDropDownIcon(), nil)
+	fo.image.IconPlacement = widget.ButtonIconTrailingText
+	fo.image.OnTapped = fo.showImageMenu
+	fo.refreshButtons()
 
 	fo.FileSelector.OnSelected = func(path string) {
-		if slices.Index[[]string, string](fo.paths, path) == -1 {
-			fo.paths = append(fo.paths, path)
+		if fo.indexOfPath(path) == -1 {
+			before := fo.Pages()
+			fo.pages = append(fo.pages, &document.Page{ID: fo.newID(), Path: path})
+			fo.record("Add Page", before)
 		}
 		if fo.OnSelected != nil {
 			fo.OnSelected(path)
 		}
-		refreshButtons()
-		fo.list.Refresh()
+		fo.refreshButtons()
+		fo.refreshList()
 	}
 
 	fo.FileSelector.OnUnselected = func(path string) {
-		if idx := slices.Index[[]string, string](fo.paths, path); idx != -1 {
-			fo.paths = append(fo.paths[:idx], fo.paths[idx+1:]...)
+		// Unselecting a file removes all pages showing it.
+		if fo.indexOfPath(path) != -1 {
+			before := fo.Pages()
+			for idx := fo.indexOfPath(path); idx != -1; idx = fo.indexOfPath(path) {
+				fo.removePage(idx)
+			}
+			fo.record("Remove Page", before)
 		}
 		if fo.OnUnselected != nil {
 			fo.OnUnselected(path)
 		}
-		refreshButtons()
-		fo.list.Refresh()
+		if _, ok := paste.Name(path); ok {
+			fo.pastedRefs[path] += 0
+		}
+		fo.releasePasted()
+		fo.refreshButtons()
+		fo.refreshList()
 	}
 
 	fo.obj = container.NewBorder(
-		container.NewBorder(nil, nil, nil, container.NewHBox(
+		container.NewBorder(nil, nil, container.NewHBox(fo.undo, fo.redo, fo.paste, fo.insert, fo.sections, fo.image, fo.sort, fo.duplex), container.NewHBox(
+			fo.rotate,
+			fo.duplicate,
+			fo.remove,
 			fo.moveDown,
 			fo.moveUp,
 			fo.moveDownFull,
 			fo.moveUpFull,
 		)),
 		nil, nil, nil,
-		fo.list,
+		container.NewStack(fo.list, container.NewWithoutLayout(fo.dropMarker, fo.tooltip)),
 	)
 }
 
@@ -166,12 +350,855 @@ func (fo *FileOverview) CreateRenderer() fyne.WidgetRenderer {
 	return widget.NewSimpleRenderer(fo.obj)
 }
 
+func showMenuBelow(b *widget.Button, menu *fyne.Menu) {
+	d := fyne.CurrentApp().Driver()
+	pos := d.AbsolutePositionForObject(b).AddXY(0, b.Size().Height)
+	widget.ShowPopUpMenuAtPosition(menu, d.CanvasForObject(b), pos)
+}
+
+// Returns the window showing obj, or nil.
+func windowOf(obj fyne.CanvasObject) fyne.Window {
+	d := fyne.CurrentApp().Driver()
+	c := d.CanvasForObject(obj)
+	for _, w := range d.AllWindows() {
+		if w.Canvas() == c {
+			return w
+		}
+	}
+	return nil
+}
+
+// Lets the user choose a colour and calls fn with it.
+func (fo *FileOverview) pickColour(title string, fn func(color.NRGBA)) {
+	w := windowOf(fo)
+	if w == nil {
+		return
+	}
+	picker := dialog.NewColorPicker(title, "Choose a colour", func(c color.Color) {
+		fn(color.NRGBAModel.Convert(c).(color.NRGBA))
+	}, w)
+	picker.Advanced = true
+	picker.Show()
+}
+
+func (fo *FileOverview) indexOfPath(path string) int {
+	for i, p := range fo.pages {
+		if p.Path == path {
+			return i
+		}
+	}
+	return -1
+}
+
+func (fo *FileOverview) indexOfID(id int) int {
+	for i, p := range fo.pages {
+		if p.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (fo *FileOverview) newID() int {
+	fo.nextID++
+	return fo.nextID
+}
+
+func (fo *FileOverview) removePage(idx int) {
+	page := fo.pages[idx]
+	fo.pages = append(fo.pages[:idx], fo.pages[idx+1:]...)
+	delete(fo.selected, page)
+	if fo.anchor == page {
+		fo.anchor = nil
+	}
+}
+
+func (fo *FileOverview) isSelected(idx int) bool {
+	_, ok := fo.selected[fo.pages[idx]]
+	return ok
+}
+
+func (fo *FileOverview) refreshButtons() {
+	canUp := !slices.Equal(fo.movedOrder(true, false), fo.pages)
+	canDown := !slices.Equal(fo.movedOrder(false, false), fo.pages)
+	setEnabled := func(b *widget.Button, enabled bool) {
+		if enabled {
+			b.Enable()
+		} else {
+			b.Disable()
+		}
+	}
+	setEnabled(fo.moveUp, canUp)
+	setEnabled(fo.moveUpFull, canUp)
+	setEnabled(fo.moveDown, canDown)
+	setEnabled(fo.moveDownFull, canDown)
+	setEnabled(fo.rotate, len(fo.selected) > 0)
+	setEnabled(fo.duplicate, len(fo.selected) > 0)
+	setEnabled(fo.remove, len(fo.selected) > 0)
+	setEnabled(fo.undo, fo.History.CanUndo())
+	setEnabled(fo.redo, fo.History.CanRedo())
+}
+
+// Records the change from before to the current pages as an undo step.
+func (fo *FileOverview) record(name string, before []document.Page) {
+	if fo.History.Applying() {
+		return
+	}
+	after := fo.Pages()
+	fo.refPasted(before, 1)
+	fo.refPasted(after, 1)
+	fo.History.Add(name, func() {
+		fo.restore(before)
+	}, func() {
+		fo.restore(after)
+	}, func() {
+		fo.refPasted(before, -1)
+		fo.refPasted(after, -1)
+		fo.releasePasted()
+	})
+}
+
+func (fo *FileOverview) refPasted(pages []document.Page, delta int) {
+	// Blank pages have no path, so paste.Name ignores them.
+	for _, p := range pages {
+		if _, ok := paste.Name(p.Path); ok {
+			fo.pastedRefs[p.Path] += delta
+		}
+	}
+}
+
+// Deletes pasted images which are neither a page nor can be brought back by
+// undo or redo.
+func (fo *FileOverview) releasePasted() {
+	for path, n := range fo.pastedRefs {
+		if n > 0 || fo.indexOfPath(path) != -1 {
+			continue
+		}
+		delete(fo.pastedRefs, path)
+		if err := paste.Remove(path); err != nil {
+			log.Println("FileOverview: Remove pasted image:", err)
+		}
+	}
+}
+
+// Makes the pages equal to pages, selecting and unselecting files in the
+// FileSelector as needed.
+func (fo *FileOverview) restore(pages []document.Page) {
+	res := make([]*document.Page, len(pages))
+	for i, p := range pages {
+		if idx := fo.indexOfID(p.ID); idx != -1 {
+			res[i] = fo.pages[idx]
+		} else {
+			res[i] = &document.Page{}
+		}
+		*res[i] = p
+	}
+	old := fo.pages
+	fo.setOrder("", res)
+	fo.syncFileSelector(old)
+	if fo.OnPagesChanged != nil {
+		fo.OnPagesChanged()
+	}
+}
+
+// Unselects the files of removed pages which are no longer on any page and
+// selects the files of all pages.
+func (fo *FileOverview) syncFileSelector(removed []*document.Page) {
+	for _, p := range removed {
+		if p.Kind != document.ImagePage {
+			continue
+		}
+		if fo.indexOfPath(p.Path) == -1 && fo.FileSelector.IsSelected(p.Path) {
+			fo.FileSelector.Unselect(p.Path)
+		}
+	}
+	for _, p := range fo.pages {
+		if p.Kind == document.ImagePage && !fo.FileSelector.IsSelected(p.Path) {
+			fo.FileSelector.Select(p.Path)
+		}
+	}
+}
+
+// Updates the rows and refreshes the list.
+func (fo *FileOverview) refreshList() {
+	fo.rows = fo.rows[:0]
+	hidden := false
+	for i, p := range fo.pages {
+		if p.Kind == document.SectionStart {
+			_, hidden = fo.collapsed[p.ID]
+		} else if hidden {
+			continue
+		}
+		fo.rows = append(fo.rows, i)
+	}
+	fo.list.Refresh()
+}
+
+// Applies a click on a row: a plain click selects only that row, Ctrl
+// toggles it and Shift selects the range from the last clicked row.
+func (fo *FileOverview) tapRow(id widget.ListItemID, mod fyne.KeyModifier) {
+	fo.hideTooltip(nil)
+	if id < 0 || id >= len(fo.rows) {
+		return
+	}
+	page := fo.pages[fo.rows[id]]
+	switch {
+	case mod&fyne.KeyModifierShift != 0 && fo.anchor != nil:
+		from := -1
+		for row, i := range fo.rows {
+			if fo.pages[i] == fo.anchor {
+				from = row
+			}
+		}
+		if from == -1 {
+			from = id
+		}
+		if from > id {
+			from, id = id, from
+		}
+		fo.selected = make(map[*document.Page]struct{})
+		for row := from; row <= id; row++ {
+			fo.selected[fo.pages[fo.rows[row]]] = struct{}{}
+		}
+	case mod&fyne.KeyModifierShortcutDefault != 0:
+		if _, ok := fo.selected[page]; ok {
+			delete(fo.selected, page)
+		} else {
+			fo.selected[page] = struct{}{}
+		}
+		fo.anchor = page
+	default:
+		fo.selected = map[*document.Page]struct{}{page: {}}
+		fo.anchor = page
+	}
+	fo.refreshButtons()
+	fo.refreshList()
+}
+
+// Returns the insertion position in rows (0 to len(rows)) for a row being
+// dragged to y, relative to the top of the row.
+func (fo *FileOverview) dropSlot(item *pageItem, y float32) int {
+	pitch := item.Size().Height + theme.Padding()
+	slot := item.ID + int(math.Round(float64(y/pitch)))
+	if slot < 0 {
+		slot = 0
+	}
+	if slot > len(fo.rows) {
+		slot = len(fo.rows)
+	}
+	return slot
+}
+
+// Shows the tooltip of the row after the mouse rested on it at pos.
+func (fo *FileOverview) hoverRow(item *pageItem, pos fyne.Position) {
+	if fo.PageTooltip == nil || item.ID < 0 || item.ID >= len(fo.rows) {
+		return
+	}
+	if item == fo.tooltipItem && fo.tooltip.Visible() {
+		return
+	}
+	fo.hideTooltip(item)
+	fo.tooltipItem = item
+	gen := fo.tooltipGen.Load()
+	page := *fo.pages[fo.rows[item.ID]]
+	d := fyne.CurrentApp().Driver()
+	pos = pos.Add(d.AbsolutePositionForObject(item).Subtract(d.AbsolutePositionForObject(fo.list)))
+	time.AfterFunc(tooltipDelay, func() {
+		if fo.tooltipGen.Load() != gen {
+			return
+		}
+		text := fo.PageTooltip(page)
+		if text == "" || fo.tooltipGen.Load() != gen {
+			return
+		}
+		fo.tooltipLabel.SetText(text)
+		size := fo.tooltip.MinSize()
+		// Below the mouse pointer, inside the list.
+		x := min(pos.X, fo.list.Size().Width-size.Width)
+		y := pos.Y + theme.IconInlineSize()
+		if y+size.Height > fo.list.Size().Height {
+			y = pos.Y - size.Height
+		}
+		fo.tooltip.Move(fyne.NewPos(max(x, 0), max(y, 0)))
+		fo.tooltip.Resize(size)
+		fo.tooltip.Show()
+	})
+}
+
+func (fo *FileOverview) hideTooltip(*pageItem) {
+	fo.tooltipGen.Add(1)
+	fo.tooltipItem = nil
+	fo.tooltip.Hide()
+}
+
+func (fo *FileOverview) dragRow(item *pageItem, y float32) {
+	fo.hideTooltip(item)
+	slot := fo.dropSlot(item, y)
+	d := fyne.CurrentApp().Driver()
+	itemPos := d.AbsolutePositionForObject(item).Subtract(d.AbsolutePositionForObject(fo.list))
+	pitch := item.Size().Height + theme.Padding()
+	markerY := itemPos.Y + float32(slot-item.ID)*pitch - theme.Padding()/2
+	fo.dropMarker.FillColor = theme.PrimaryColor()
+	fo.dropMarker.Move(fyne.NewPos(0, markerY-1))
+	fo.dropMarker.Resize(fyne.NewSize(fo.list.Size().Width, 2))
+	fo.dropMarker.Show()
+	fo.dropMarker.Refresh()
+}
+
+func (fo *FileOverview) dropRow(item *pageItem, y float32) {
+	fo.dropMarker.Hide()
+	if item.ID < 0 || item.ID >= len(fo.rows) {
+		return
+	}
+	// Dragging an unselected row moves only that row.
+	if page := fo.pages[fo.rows[item.ID]]; !fo.isSelected(fo.rows[item.ID]) {
+		fo.selected = map[*document.Page]struct{}{page: {}}
+		fo.anchor = page
+	}
+	slot := len(fo.pages)
+	if row := fo.dropSlot(item, y); row < len(fo.rows) {
+		slot = fo.rows[row]
+	}
+	fo.moveSelectedTo(slot)
+}
+
+// Returns the units moved by moveSelected and moveSelectedTo, each of which
+// is selected if its first page is: whole sections if a section is selected,
+// else single pages. The first fixed pages are not part of any unit and stay
+// in place.
+func (fo *FileOverview) moveUnits() (units [][]*document.Page, fixed int) {
+	ranges := fo.sectionRanges()
+	for _, r := range ranges {
+		if fo.isSelected(r[0]) {
+			fixed = len(fo.pages)
+			if len(ranges) > 0 {
+				fixed = ranges[0][0]
+			}
+			for _, r := range ranges {
+				units = append(units, fo.pages[r[0]:r[1]])
+			}
+			return units, fixed
+		}
+	}
+	for i := range fo.pages {
+		units = append(units, fo.pages[i:i+1])
+	}
+	return units, 0
+}
+
+func joinUnits(fixed []*document.Page, units [][]*document.Page) []*document.Page {
+	res := make([]*document.Page, 0, len(fixed)+len(units))
+	res = append(res, fixed...)
+	for _, u := range units {
+		res = append(res, u...)
+	}
+	return res
+}
+
+// Moves all selected pages or sections to the insertion position slot
+// (0 to len(pages)), keeping their order. Sections are moved to the closest
+// section boundary after slot.
+func (fo *FileOverview) moveSelectedTo(slot int) {
+	units, fixed := fo.moveUnits()
+	// Convert slot to the number of units before it.
+	start := fixed
+	unitSlot := 0
+	for _, u := range units {
+		if start < slot {
+			unitSlot++
+		}
+		start += len(u)
+	}
+	// Selected units before the slot don't count once taken out.
+	var sel, rest [][]*document.Page
+	restSlot := unitSlot
+	for i, u := range units {
+		if _, ok := fo.selected[u[0]]; !ok {
+			rest = append(rest, u)
+			continue
+		}
+		sel = append(sel, u)
+		if i < unitSlot {
+			restSlot--
+		}
+	}
+	if len(sel) == 0 {
+		return
+	}
+	res := make([][]*document.Page, 0, len(units))
+	res = append(res, rest[:restSlot]...)
+	res = append(res, sel...)
+	res = append(res, rest[restSlot:]...)
+	fo.setOrder("Move Pages", joinUnits(fo.pages[:fixed], res))
+}
+
+// Returns the pages after moving all selected pages or sections one step, or
+// as far as possible if full is set.
+func (fo *FileOverview) movedOrder(up bool, full bool) []*document.Page {
+	units, fixed := fo.moveUnits()
+	sel := func(i int) bool {
+		_, ok := fo.selected[units[i][0]]
+		return ok
+	}
+	if full {
+		var selUnits, rest [][]*document.Page
+		for i := range units {
+			if sel(i) {
+				selUnits = append(selUnits, units[i])
+			} else {
+				rest = append(rest, units[i])
+			}
+		}
+		if up {
+			units = append(selUnits, rest...)
+		} else {
+			units = append(rest, selUnits...)
+		}
+	} else if up {
+		for i := 1; i < len(units); i++ {
+			if sel(i) && !sel(i-1) {
+				units[i], units[i-1] = units[i-1], units[i]
+			}
+		}
+	} else {
+		for i := len(units) - 2; i >= 0; i-- {
+			if sel(i) && !sel(i+1) {
+				units[i], units[i+1] = units[i+1], units[i]
+			}
+		}
+	}
+	return joinUnits(fo.pages[:fixed], units)
+}
+
+// Moves all selected pages or sections one step, or as far as possible if
+// full is set.
+func (fo *FileOverview) moveSelected(up bool, full bool) {
+	fo.setOrder("Move Pages", fo.movedOrder(up, full))
+}
+
+// Replaces the pages, notifying OnReorder and recording an undo step called
+// name if they changed.
+func (fo *FileOverview) setOrder(name string, pages []*document.Page) {
+	changed := len(pages) != len(fo.pages)
+	for i := range pages {
+		if !changed && pages[i] != fo.pages[i] {
+			changed = true
+		}
+	}
+	before := fo.Pages()
+	fo.pages = pages
+	if changed {
+		kept := make(map[*document.Page]struct{}, len(pages))
+		for _, p := range pages {
+			kept[p] = struct{}{}
+		}
+		for p := range fo.selected {
+			if _, ok := kept[p]; !ok {
+				delete(fo.selected, p)
+			}
+		}
+		if _, ok := kept[fo.anchor]; !ok {
+			fo.anchor = nil
+		}
+	}
+	if changed {
+		fo.record(name, before)
+	}
+	fo.refreshButtons()
+	fo.refreshList()
+	if changed && fo.OnReorder != nil {
+		fo.OnReorder()
+	}
+}
+
+// Rotates all selected pages clockwise by deg degrees.
+func (fo *FileOverview) RotateSelected(deg int) {
+	pages := fo.selectedPages()
+	if len(pages) == 0 {
+		return
+	}
+	before := fo.Pages()
+	for _, p := range pages {
+		if p.Kind != document.SectionStart {
+			p.Rotation = document.NormRotation(p.Rotation + deg)
+		}
+	}
+	fo.record("Rotate Pages", before)
+	fo.refreshList()
+	if fo.OnPagesChanged != nil {
+		fo.OnPagesChanged()
+	}
+}
+
+// Removes pages, unselecting their files in the FileSelector unless they
+// are still on other pages.
+func (fo *FileOverview) removePages(name string, pages []*document.Page) {
+	remove := make(map[*document.Page]struct{}, len(pages))
+	for _, p := range pages {
+		remove[p] = struct{}{}
+	}
+	res := make([]*document.Page, 0, len(fo.pages))
+	for _, p := range fo.pages {
+		if _, ok := remove[p]; !ok {
+			res = append(res, p)
+		}
+	}
+	fo.setOrder(name, res)
+	fo.syncFileSelector(pages)
+}
+
+// Removes all selected pages, including the pages of selected sections.
+func (fo *FileOverview) RemoveSelected() {
+	fo.removePages("Remove Pages", fo.selectedPages())
+}
+
+// Inserts a copy of each selected page after it and selects the copies.
+// Section headers are not copied.
+func (fo *FileOverview) DuplicateSelected() {
+	if len(fo.selected) == 0 {
+		return
+	}
+	res := make([]*document.Page, 0, len(fo.pages)+len(fo.selected))
+	copies := make(map[*document.Page]struct{}, len(fo.selected))
+	for _, p := range fo.pages {
+		res = append(res, p)
+		if _, ok := fo.selected[p]; ok && p.Kind != document.SectionStart {
+			c := *p
+			c.ID = fo.newID()
+			res = append(res, &c)
+			copies[&c] = struct{}{}
+		}
+	}
+	if len(copies) == 0 {
+		return
+	}
+	fo.selected = copies
+	fo.anchor = nil
+	fo.setOrder("Duplicate Pages", res)
+}
+
+// Inserts a blank page with background colour bg after the last selected
+// page, or at the end if none is selected, and selects it. bg may be fully
+// transparent for a white page.
+func (fo *FileOverview) InsertBlank(bg color.NRGBA) {
+	fo.insertPage("Insert Blank Page", &document.Page{
+		ID:         fo.newID(),
+		Kind:       document.BlankPage,
+		Background: bg,
+	})
+}
+
+// Inserts a text page like InsertBlank.
+func (fo *FileOverview) InsertText(t document.Text) {
+	fo.insertPage("Insert Text Page", &document.Page{
+		ID:   fo.newID(),
+		Kind: document.TextPage,
+		Text: t,
+	})
+}
+
+func (fo *FileOverview) insertPage(name string, page *document.Page) {
+	idx := len(fo.pages)
+	if sel := fo.selectedPages(); len(sel) > 0 {
+		idx = slices.Index(fo.pages, sel[len(sel)-1]) + 1
+	}
+	res := make([]*document.Page, 0, len(fo.pages)+1)
+	res = append(res, fo.pages[:idx]...)
+	res = append(res, page)
+	res = append(res, fo.pages[idx:]...)
+	fo.selected = map[*document.Page]struct{}{page: {}}
+	fo.anchor = page
+	fo.setOrder(name, res)
+}
+
+// Reports whether page is filled with its Background.
+func hasBackground(page *document.Page) bool {
+	return page.Kind == document.BlankPage || page.Kind == document.TextPage
+}
+
+func (fo *FileOverview) backgroundSelected() bool {
+	for p := range fo.selected {
+		if hasBackground(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the selected page if it is the only one and a text page, or nil.
+func (fo *FileOverview) selectedTextPage() *document.Page {
+	if len(fo.selected) != 1 {
+		return nil
+	}
+	for p := range fo.selected {
+		if p.Kind == document.TextPage {
+			return p
+		}
+	}
+	return nil
+}
+
+// Replaces the text of the selected text page.
+func (fo *FileOverview) SetText(t document.Text) {
+	page := fo.selectedTextPage()
+	if page == nil || page.Text == t {
+		return
+	}
+	before := fo.Pages()
+	page.Text = t
+	fo.record("Edit Text Page", before)
+	fo.refreshList()
+	if fo.OnPagesChanged != nil {
+		fo.OnPagesChanged()
+	}
+}
+
+// Sets the background colour of all selected blank and text pages.
+func (fo *FileOverview) SetBackground(bg color.NRGBA) {
+	if !fo.backgroundSelected() {
+		return
+	}
+	before := fo.Pages()
+	for p := range fo.selected {
+		if hasBackground(p) {
+			p.Background = bg
+		}
+	}
+	fo.record("Set Background", before)
+	fo.refreshList()
+	if fo.OnPagesChanged != nil {
+		fo.OnPagesChanged()
+	}
+}
+
+// Interleaves the fronts and backs of simplex scans to front 1, back 1,
+// front 2, back 2, ...
+//
+// If exactly two groups of consecutive pages are selected, the first holds
+// the fronts and the second the backs. Otherwise the first half of all pages
+// are the fronts and the second half the backs, not counting section
+// headers. If reverse is set, the backs are in reverse order, as when the
+// stack of pages was flipped for scanning.
+//
+// If there are fewer backs than fronts, the last fronts are the ones without
+// a back. If blanks is set, a blank page is added after each of them.
+func (fo *FileOverview) InterleaveDuplex(reverse, blanks bool) {
+	// Find the groups of selected pages as [start, end) ranges.
+	var groups [][2]int
+	for i := range fo.pages {
+		if !fo.isSelected(i) {
+			continue
+		}
+		if n := len(groups); n > 0 && groups[n-1][1] == i {
+			groups[n-1][1] = i + 1
+		} else {
+			groups = append(groups, [2]int{i, i + 1})
+		}
+	}
+	if len(groups) != 2 {
+		printed := 0
+		for _, p := range fo.pages {
+			if p.Kind != document.SectionStart {
+				printed++
+			}
+		}
+		// Just after the last front.
+		half, fronts := 0, 0
+		for ; fronts < (printed+1)/2; half++ {
+			if fo.pages[half].Kind != document.SectionStart {
+				fronts++
+			}
+		}
+		groups = [][2]int{{0, half}, {half, len(fo.pages)}}
+	}
+	// Section headers within the groups stay in front of the interleaved
+	// pages.
+	var headers, fronts, backs []*document.Page
+	split := func(group [2]int) (pages []*document.Page) {
+		for _, p := range fo.pages[group[0]:group[1]] {
+			if p.Kind == document.SectionStart {
+				headers = append(headers, p)
+			} else {
+				pages = append(pages, p)
+			}
+		}
+		return pages
+	}
+	fronts = split(groups[0])
+	backs = split(groups[1])
+	if reverse {
+		slices.Reverse(backs)
+	}
+
+	res := make([]*document.Page, 0, len(fo.pages)+len(fronts))
+	res = append(res, fo.pages[:groups[0][0]]...)
+	res = append(res, headers...)
+	for i := 0; i < len(fronts) || i < len(backs); i++ {
+		if i < len(fronts) {
+			res = append(res, fronts[i])
+		}
+		if i < len(backs) {
+			res = append(res, backs[i])
+		} else if blanks {
+			res = append(res, &document.Page{ID: fo.newID(), Kind: document.BlankPage})
+		}
+	}
+	res = append(res, fo.pages[groups[0][1]:groups[1][0]]...)
+	res = append(res, fo.pages[groups[1][1]:]...)
+	fo.setOrder("Interleave Duplex", res)
+}
+
+type PageSortKey int
+
+const (
+	// Natural order of the file names.
+	SortPagesByName PageSortKey = iota
+	// File modification time.
+	SortPagesByModified
+	// EXIF DateTimeOriginal, falling back to the modification time.
+	SortPagesByDateTaken
+)
+
+func modTime(path string) time.Time {
+	st, err := archive.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return st.ModTime()
+}
+
+// Sorts the pages of each section by key. Pages with equal keys are ordered
+// by name, then keep their previous order.
+//
+// Dates are read in the background. If the pages change in the meantime,
+// they are read again.
+func (fo *FileOverview) SortPages(key PageSortKey, descending bool) {
+	pages := slices.Clone(fo.pages)
+	if key == SortPagesByName {
+		fo.setOrder("Sort Pages", sortSections(pages, nil, descending))
+		return
+	}
+	paths := make([]string, len(pages))
+	for i, page := range pages {
+		paths[i] = page.Path
+	}
+	go func() {
+		times := make([]time.Time, len(paths))
+		for i, path := range paths {
+			if path == "" {
+				continue
+			}
+			switch key {
+			case SortPagesByModified:
+				times[i] = modTime(path)
+			case SortPagesByDateTaken:
+				t, err := exifdate.Get(path)
+				if err != nil {
+					t = modTime(path)
+				}
+				times[i] = t
+			}
+		}
+		fo.queueEvent(func() {
+			if !slices.Equal(pages, fo.pages) {
+				fo.SortPages(key, descending)
+				return
+			}
+			fo.setOrder("Sort Pages", sortSections(pages, times, descending))
+		})
+	}()
+}
+
+func (fo *FileOverview) queueEvent(fn func()) {
+	if fo.QueueEvent != nil {
+		fo.QueueEvent(fn)
+	} else {
+		fn()
+	}
+}
+
+// Sorts the pages of each section by times, which is nil or holds the time
+// of each page, and then by name.
+func sortSections(pages []*document.Page, times []time.Time, descending bool) []*document.Page {
+	res := make([]*document.Page, 0, len(pages))
+	start := 0
+	for i := 0; i <= len(pages); i++ {
+		if i == len(pages) || pages[i].Kind == document.SectionStart {
+			var t []time.Time
+			if times != nil {
+				t = times[start:i]
+			}
+			res = append(res, sortPages(pages[start:i], t, descending)...)
+			if i < len(pages) {
+				res = append(res, pages[i])
+			}
+			start = i + 1
+		}
+	}
+	return res
+}
+
+func sortPages(pages []*document.Page, times []time.Time, descending bool) []*document.Page {
+	type sortItem struct {
+		page *document.Page
+		name string
+		t    time.Time
+	}
+	items := make([]sortItem, len(pages))
+	for i, page := range pages {
+		items[i] = sortItem{page: page, name: pageName(*page)}
+		if times != nil {
+			items[i].t = times[i]
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if descending {
+			a, b = b, a
+		}
+		if !a.t.Equal(b.t) {
+			return a.t.Before(b.t)
+		}
+		if c := natsort.Compare(a.name, b.name); c != 0 {
+			return c < 0
+		}
+		return natsort.Less(a.page.Path, b.page.Path)
+	})
+	res := make([]*document.Page, len(items))
+	for i := range items {
+		res[i] = items[i].page
+	}
+	return res
+}
+
+// Adds the image in the system clipboard as a new page.
+func (fo *FileOverview) PasteImage() {
+	path, err := paste.FromClipboard()
+	if err != nil {
+		if fo.OnError != nil {
+			fo.OnError(err)
+		}
+		return
+	}
+	fo.FileSelector.Select(path)
+}
+
+// Returns the number of pages, not counting section headers.
 func (fo *FileOverview) NumSelected() int {
-	return len(fo.paths)
+	n := 0
+	for _, p := range fo.pages {
+		if p.Kind != document.SectionStart {
+			n++
+		}
+	}
+	return n
 }
 
-func (fo *FileOverview) Selected() []string {
-	res := make([]string, len(fo.paths))
-	copy(res, fo.paths)
+// Returns a copy of all pages in order, including section headers.
+func (fo *FileOverview) Pages() []document.Page {
+	res := make([]document.Page, len(fo.pages))
+	for i, p := range fo.pages {
+		res[i] = *p
+	}
 	return res
 }
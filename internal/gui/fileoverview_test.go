@@ -0,0 +1,182 @@
+package gui
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"fyne.io/fyne/v2/test"
+
+	"github.com/pic4pdf/pic4pdf/internal/document"
+)
+
+// Returns a FileOverview with pages described like in order, e.g.
+// "a b [S] c" for the image pages a and b followed by section S with
+// page c. "_" is a blank page and "[]" an untitled SectionStart.
+func newTestOverview(t *testing.T, pages string) *FileOverview {
+	t.Helper()
+	test.NewApp()
+	fo := NewFileOverview(NewFileSelector())
+	for _, name := range strings.Fields(pages) {
+		p := &document.Page{ID: fo.newID()}
+		switch {
+		case strings.HasPrefix(name, "["):
+			p.Kind = document.SectionStart
+			p.Title = strings.Trim(name, "[]")
+		case name == "_":
+			p.Kind = document.BlankPage
+		default:
+			p.Path = filepath.Join("/pictures", name+".jpg")
+		}
+		fo.pages = append(fo.pages, p)
+	}
+	fo.refreshList()
+	return fo
+}
+
+// Describes pages in the format of newTestOverview.
+func order(pages []*document.Page) string {
+	var res []string
+	for _, p := range pages {
+		switch p.Kind {
+		case document.SectionStart:
+			res = append(res, "["+p.Title+"]")
+		case document.BlankPage:
+			res = append(res, "_")
+		default:
+			res = append(res, strings.TrimSuffix(filepath.Base(p.Path), ".jpg"))
+		}
+	}
+	return strings.Join(res, " ")
+}
+
+// Selects the pages with the given names.
+func selectPages(fo *FileOverview, names ...string) {
+	fo.selected = make(map[*document.Page]struct{})
+	for _, name := range names {
+		for _, p := range fo.pages {
+			if order([]*document.Page{p}) == name {
+				fo.selected[p] = struct{}{}
+			}
+		}
+	}
+}
+
+func TestMoveSelectedTo(t *testing.T) {
+	tests := []struct {
+		pages    string
+		selected []string
+		slot     int
+		want     string
+	}{
+		{"a b c d e", []string{"b", "d"}, 0, "b d a c e"},
+		{"a b c d e", []string{"b", "d"}, 3, "a c b d e"},
+		{"a b c d e", []string{"b", "d"}, 5, "a c e b d"},
+		{"a b c d e", []string{"a", "e"}, 2, "b a e c d"},
+		{"a b c d e", []string{"b", "c"}, 2, "a b c d e"},
+		// Sections move to the end of the section at slot, and pages
+		// before the first section stay in place.
+		{"x [S1] a b [S2] c [S3] d", []string{"[S3]"}, 0, "x [S3] d [S1] a b [S2] c"},
+		{"x [S1] a b [S2] c [S3] d", []string{"[S1]", "[S3]"}, 6, "x [S2] c [S1] a b [S3] d"},
+		{"x [S1] a b [S2] c [S3] d", []string{"[S1]"}, 5, "x [S2] c [S1] a b [S3] d"},
+		{"x [S1] a b [S2] c [S3] d", []string{"[S1]"}, 3, "x [S1] a b [S2] c [S3] d"},
+		// Pages move in and out of sections.
+		{"[S1] a b [S2] c", []string{"a", "c"}, 5, "[S1] b [S2] a c"},
+		{"[S1] a b [S2] c", []string{"c"}, 1, "[S1] c a b [S2]"},
+	}
+	for _, tt := range tests {
+		fo := newTestOverview(t, tt.pages)
+		selectPages(fo, tt.selected...)
+		fo.moveSelectedTo(tt.slot)
+		if got := order(fo.pages); got != tt.want {
+			t.Errorf("%v, moving %v to %v: got %v, want %v", tt.pages, tt.selected, tt.slot, got, tt.want)
+		}
+	}
+}
+
+func TestMovedOrder(t *testing.T) {
+	tests := []struct {
+		pages    string
+		selected []string
+		up, full bool
+		want     string
+	}{
+		{"a b c d e", []string{"a", "c"}, true, false, "a c b d e"},
+		{"a b c d e", []string{"a", "c"}, true, true, "a c b d e"},
+		{"a b c d e", []string{"b", "d"}, false, false, "a c b e d"},
+		{"a b c d e", []string{"a", "c"}, false, true, "b d e a c"},
+		{"a b c d e", []string{"d", "e"}, false, false, "a b c d e"},
+		{"x [S1] a [S2] b [S3] c", []string{"[S1]", "[S3]"}, false, false, "x [S2] b [S1] a [S3] c"},
+		{"x [S1] a [S2] b [S3] c", []string{"[S3]"}, true, true, "x [S3] c [S1] a [S2] b"},
+	}
+	for _, tt := range tests {
+		fo := newTestOverview(t, tt.pages)
+		selectPages(fo, tt.selected...)
+		if got := order(fo.movedOrder(tt.up, tt.full)); got != tt.want {
+			t.Errorf("%v, moving %v (up %v, full %v): got %v, want %v", tt.pages, tt.selected, tt.up, tt.full, got, tt.want)
+		}
+	}
+}
+
+func TestMoveCollapsedSection(t *testing.T) {
+	fo := newTestOverview(t, "[S1] a b [S2] c [S3] d e")
+	fo.ToggleCollapsed(fo.pages[0])
+	if got := len(fo.rows); got != 6 {
+		t.Fatalf("%v rows with S1 collapsed, want 6", got)
+	}
+	// Dragging the header of the collapsed section below S2.
+	fo.tapRow(0, 0)
+	fo.moveSelectedTo(fo.rows[2])
+	if got, want := order(fo.pages), "[S2] c [S1] a b [S3] d e"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if _, ok := fo.collapsed[fo.pages[2].ID]; !ok {
+		t.Errorf("S1 was expanded")
+	}
+	fo.moveSelected(false, true)
+	if got, want := order(fo.pages), "[S2] c [S3] d e [S1] a b"; got != want {
+		t.Errorf("moved to the end: got %v, want %v", got, want)
+	}
+	fo.History.Undo()
+	fo.History.Undo()
+	if got, want := order(fo.pages), "[S1] a b [S2] c [S3] d e"; got != want {
+		t.Errorf("after undo: got %v, want %v", got, want)
+	}
+}
+
+func TestInterleaveDuplex(t *testing.T) {
+	tests := []struct {
+		pages           string
+		selected        []string
+		reverse, blanks bool
+		want            string
+	}{
+		// Halves of all pages.
+		{"a b c d", nil, false, false, "a c b d"},
+		{"a b c d", nil, true, false, "a d b c"},
+		{"a b c d e", nil, false, false, "a d b e c"},
+		{"a b c d e", nil, false, true, "a d b e c _"},
+		{"a b c d e", nil, true, false, "a e b d c"},
+		{"a b c d e", nil, true, true, "a e b d c _"},
+		{"[h1] [h2] a b c d", nil, false, false, "[h1] [h2] a c b d"},
+		{"[S] a b [T] c d", nil, false, false, "[S] [T] a c b d"},
+		{"a b [S] c d e", nil, false, false, "[S] a d b e c"},
+		// Two selected groups.
+		{"x a b y c d z", []string{"a", "b", "c", "d"}, false, false, "x a c b d y z"},
+		{"x a b y c d z", []string{"a", "b", "c", "d"}, true, false, "x a d b c y z"},
+		{"a b c x d", []string{"a", "b", "c", "d"}, false, false, "a d b c x"},
+		{"a b c x d", []string{"a", "b", "c", "d"}, false, true, "a d b _ c _ x"},
+		{"a b c x d", []string{"a", "b", "c", "d"}, true, true, "a d b _ c _ x"},
+		{"a x b c d", []string{"a", "b", "c", "d"}, false, true, "a b c d x"},
+		// More than two groups split all pages in half.
+		{"a b c d e f", []string{"a", "c", "e"}, false, false, "a d b e c f"},
+	}
+	for _, tt := range tests {
+		fo := newTestOverview(t, tt.pages)
+		selectPages(fo, tt.selected...)
+		fo.InterleaveDuplex(tt.reverse, tt.blanks)
+		if got := order(fo.pages); got != tt.want {
+			t.Errorf("%v, %v selected, reverse %v, blanks %v: got %v, want %v", tt.pages, tt.selected, tt.reverse, tt.blanks, got, tt.want)
+		}
+	}
+}
@@ -3,7 +3,6 @@ package gui
 import (
 	"io/fs"
 	"log"
-	"os"
 	"path"
 	"path/filepath"
 	"sort"
@@ -17,6 +16,11 @@ import (
 	"github.com/adrg/xdg"
 	usbdrivedetector "github.com/deepakjois/gousbdrivedetector"
 	"github.com/fsnotify/fsnotify"
+
+	"github.com/pic4pdf/pic4pdf/internal/archive"
+	"github.com/pic4pdf/pic4pdf/internal/history"
+	"github.com/pic4pdf/pic4pdf/internal/natsort"
+	"github.com/pic4pdf/pic4pdf/internal/raw"
 )
 
 type fileList struct {
@@ -65,7 +69,11 @@ func (fl *fileList) ExtendBaseWidget(w fyne.Widget) {
 				item.LabelButton.Enable()
 				item.IconButton.Hide()
 			} else {
-				item.LabelIcon.SetResource(theme.FileImageIcon())
+				if raw.IsRaw(entry.Name()) {
+					item.LabelIcon.SetResource(theme.MediaPhotoIcon())
+				} else {
+					item.LabelIcon.SetResource(theme.FileImageIcon())
+				}
 				item.LabelButton.Disable()
 				item.IconButton.Show()
 			}
@@ -108,6 +116,54 @@ func (fl *fileList) CreateRenderer() fyne.WidgetRenderer {
 	return widget.NewSimpleRenderer(fl.list)
 }
 
+// File list sort orders.
+const (
+	sortByName     = "Name"
+	sortByModified = "Modified"
+	sortBySize     = "Size"
+	sortByType     = "Type"
+)
+
+// Sorts ents in ascending order by the given key, directories first.
+// Ties are broken by natural name order.
+func sortFileEntries(ents []fs.DirEntry, by string) {
+	infos := make(map[fs.DirEntry]fs.FileInfo, len(ents))
+	info := func(ent fs.DirEntry) fs.FileInfo {
+		if inf, ok := infos[ent]; ok {
+			return inf
+		}
+		inf, _ := ent.Info()
+		infos[ent] = inf
+		return inf
+	}
+	sort.SliceStable(ents, func(i, j int) bool {
+		a, b := ents[i], ents[j]
+		if a.IsDir() != b.IsDir() {
+			return a.IsDir()
+		}
+		switch by {
+		case sortByModified, sortBySize:
+			ia, ib := info(a), info(b)
+			if ia == nil || ib == nil {
+				break
+			}
+			if by == sortByModified && !ia.ModTime().Equal(ib.ModTime()) {
+				return ia.ModTime().Before(ib.ModTime())
+			}
+			if by == sortBySize && ia.Size() != ib.Size() {
+				return ia.Size() < ib.Size()
+			}
+		case sortByType:
+			ea := strings.ToLower(filepath.Ext(a.Name()))
+			eb := strings.ToLower(filepath.Ext(b.Name()))
+			if ea != eb {
+				return ea < eb
+			}
+		}
+		return natsort.Less(a.Name(), b.Name())
+	})
+}
+
 type FileSelector struct {
 	widget.BaseWidget
 
@@ -115,7 +171,9 @@ type FileSelector struct {
 	backButton    *widget.Button
 	forwardButton *widget.Button
 	showHidden    *widget.Check
+	selectAll     *widget.Button
 	filter        *widget.Entry
+	sortBy        *widget.Select
 	quickAccess   *fyne.Container
 	list          *fileList
 	listMessage   *widget.Label
@@ -123,6 +181,8 @@ type FileSelector struct {
 
 	OnSelected   func(path string)
 	OnUnselected func(path string)
+	// If set, selecting all files is recorded as a single undo step.
+	History *history.History
 
 	validFilename        func(name string) bool
 	path                 string
@@ -138,9 +198,9 @@ func (f *FileSelector) refreshList() {
 		for _, v := range f.watcher.WatchList() {
 			f.watcher.Remove(v)
 		}
-		f.watcher.Add(f.path)
+		f.watcher.Add(f.watchPath())
 	}
-	ents, err := os.ReadDir(f.path)
+	ents, err := archive.ReadDir(f.path)
 	if err != nil {
 		f.listMessage.Show()
 		f.listMessage.SetText("Error: " + err.Error())
@@ -163,6 +223,7 @@ func (f *FileSelector) refreshList() {
 			res = append(res, ent)
 		}
 	}
+	sortFileEntries(res, f.sortBy.Selected)
 	f.list.SetEntries(res)
 	if len(res) == 0 {
 		f.listMessage.Show()
@@ -188,6 +249,14 @@ func (f *FileSelector) refreshList() {
 	}
 }
 
+// Returns the path on disk to watch for changes of the current directory.
+func (f *FileSelector) watchPath() string {
+	if arPath, _, ok := archive.Split(f.path); ok {
+		return arPath
+	}
+	return f.path
+}
+
 func (f *FileSelector) refreshQuickAccess() {
 	var quickAccessButtons []fyne.CanvasObject
 	userDirPaths := []string{xdg.Home, xdg.UserDirs.Pictures, xdg.UserDirs.Documents, xdg.UserDirs.Download, xdg.UserDirs.Desktop}
@@ -298,7 +367,7 @@ func (f *FileSelector) ExtendBaseWidget(w fyne.Widget) {
 	f.pathEntry.Validator = func(s string) error { return nil }
 	f.pathEntry.OnChanged = func(s string) {
 		if s != f.path {
-			_, err := os.Stat(s)
+			_, err := archive.Stat(s)
 			if err == nil {
 				f.cd(s)
 			}
@@ -316,6 +385,9 @@ func (f *FileSelector) ExtendBaseWidget(w fyne.Widget) {
 	f.showHidden = widget.NewCheck("Show Hidden", func(b bool) {
 		f.refreshList()
 	})
+	f.selectAll = widget.NewButton("Select All", func() {
+		f.SelectAll()
+	})
 	f.filter = widget.NewEntry()
 	searchIcon := widget.NewIcon(theme.SearchIcon())
 	clearButton := widget.NewButtonWithIcon("", theme.CancelIcon(), func() {
@@ -334,6 +406,19 @@ func (f *FileSelector) ExtendBaseWidget(w fyne.Widget) {
 		}
 		f.refreshList()
 	}
+	f.sortBy = widget.NewSelect(
+		[]string{sortByName, sortByModified, sortBySize, sortByType},
+		func(s string) {
+			if f.preferencesID != "" {
+				fyne.CurrentApp().Preferences().SetString("FileSelectorSort"+f.preferencesID, s)
+			}
+			f.refreshList()
+		},
+	)
+	f.sortBy.Selected = sortByName
+	if f.preferencesID != "" {
+		f.sortBy.Selected = fyne.CurrentApp().Preferences().StringWithFallback("FileSelectorSort"+f.preferencesID, sortByName)
+	}
 	f.quickAccess = container.NewGridWithColumns(3)
 	quickAccessAccordion := widget.NewAccordion(widget.NewAccordionItem(
 		"Quick Access",
@@ -372,12 +457,13 @@ func (f *FileSelector) ExtendBaseWidget(w fyne.Widget) {
 				nil,
 				container.NewBorder(nil, nil, nil, container.NewHBox(
 					f.showHidden,
+					f.selectAll,
 					f.backButton,
 					f.forwardButton,
 				)),
 				f.pathEntry,
 			),
-			f.filter,
+			container.NewBorder(nil, nil, nil, f.sortBy, f.filter),
 		),
 		quickAccessAccordion,
 		nil, nil,
@@ -435,7 +521,7 @@ func (f *FileSelector) CreateWatcher(onError func(error)) (close func() error, e
 		}
 	}()
 
-	f.watcher.Add(f.path)
+	f.watcher.Add(f.watchPath())
 
 	return func() error {
 		quit <- struct{}{}
@@ -483,6 +569,26 @@ func (f *FileSelector) Unselect(path string) {
 	f.refreshList()
 }
 
+// Selects all files listed in the current directory.
+func (f *FileSelector) SelectAll() {
+	f.History.Group("Select All", func() {
+		for _, ent := range f.list.entries {
+			if ent.IsDir() {
+				continue
+			}
+			path := path.Join(f.path, ent.Name())
+			if _, ok := f.selected[path]; ok {
+				continue
+			}
+			f.selected[path] = struct{}{}
+			if f.OnSelected != nil {
+				f.OnSelected(path)
+			}
+		}
+	})
+	f.refreshList()
+}
+
 func (f *FileSelector) NumSelected() int {
 	return len(f.selected)
 }
@@ -0,0 +1,81 @@
+package gui
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/widget"
+)
+
+// A FileOverview row which can be selected using modifier keys and dragged
+// to a new position.
+type pageItem struct {
+	widget.BaseWidget
+
+	Item *FileItem
+	ID   widget.ListItemID
+
+	// Called with the modifiers held while tapping.
+	OnTapped func(id widget.ListItemID, mod fyne.KeyModifier)
+	// y is the vertical drag position relative to the item's top.
+	OnDragged func(item *pageItem, y float32)
+	OnDragEnd func(item *pageItem, y float32)
+	// Called when the mouse enters or moves over the item, with its
+	// position relative to the item, and when it leaves.
+	OnHover    func(item *pageItem, pos fyne.Position)
+	OnHoverEnd func(item *pageItem)
+
+	mod   fyne.KeyModifier
+	dragY float32
+}
+
+func newPageItem(item *FileItem) *pageItem {
+	pi := &pageItem{Item: item}
+	pi.ExtendBaseWidget(pi)
+	return pi
+}
+
+func (pi *pageItem) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(pi.Item)
+}
+
+func (pi *pageItem) MouseDown(e *desktop.MouseEvent) {
+	pi.mod = e.Modifier
+}
+
+func (pi *pageItem) MouseUp(*desktop.MouseEvent) {
+}
+
+func (pi *pageItem) Tapped(*fyne.PointEvent) {
+	if pi.OnTapped != nil {
+		pi.OnTapped(pi.ID, pi.mod)
+	}
+}
+
+func (pi *pageItem) Dragged(e *fyne.DragEvent) {
+	pi.dragY = e.Position.Y
+	if pi.OnDragged != nil {
+		pi.OnDragged(pi, pi.dragY)
+	}
+}
+
+func (pi *pageItem) DragEnd() {
+	if pi.OnDragEnd != nil {
+		pi.OnDragEnd(pi, pi.dragY)
+	}
+}
+
+func (pi *pageItem) MouseIn(e *desktop.MouseEvent) {
+	pi.MouseMoved(e)
+}
+
+func (pi *pageItem) MouseMoved(e *desktop.MouseEvent) {
+	if pi.OnHover != nil {
+		pi.OnHover(pi, e.Position)
+	}
+}
+
+func (pi *pageItem) MouseOut() {
+	if pi.OnHoverEnd != nil {
+		pi.OnHoverEnd(pi)
+	}
+}
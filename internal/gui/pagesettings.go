@@ -0,0 +1,94 @@
+package gui
+
+import (
+	"fyne.io/fyne/v2"
+
+	"github.com/pic4pdf/pic4pdf/internal/document"
+)
+
+// Returns the selected image pages, including those in selected sections.
+func (fo *FileOverview) selectedImagePages() []*document.Page {
+	var res []*document.Page
+	for _, p := range fo.selectedPages() {
+		if p.Kind == document.ImagePage {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// Sets how the images of the selected pages are compressed.
+func (fo *FileOverview) SetCompression(c document.Compression) {
+	pages := fo.selectedImagePages()
+	changed := false
+	for _, p := range pages {
+		changed = changed || p.Compression != c
+	}
+	if !changed {
+		return
+	}
+	before := fo.Pages()
+	for _, p := range pages {
+		p.Compression = c
+	}
+	fo.record("Set Compression", before)
+	fo.refreshList()
+	if fo.OnPagesChanged != nil {
+		fo.OnPagesChanged()
+	}
+}
+
+// Sets the colour mode of the images of the selected pages.
+func (fo *FileOverview) SetColourMode(m document.ColourMode) {
+	pages := fo.selectedImagePages()
+	changed := false
+	for _, p := range pages {
+		changed = changed || p.ColourMode != m
+	}
+	if !changed {
+		return
+	}
+	before := fo.Pages()
+	for _, p := range pages {
+		p.ColourMode = m
+	}
+	fo.record("Set Colour Mode", before)
+	fo.refreshList()
+	if fo.OnPagesChanged != nil {
+		fo.OnPagesChanged()
+	}
+}
+
+func (fo *FileOverview) showImageMenu() {
+	pages := fo.selectedImagePages()
+	compression := func(label string, c document.Compression) *fyne.MenuItem {
+		item := fyne.NewMenuItem(label, func() { fo.SetCompression(c) })
+		item.Disabled = len(pages) == 0
+		// Checked if all selected pages have it.
+		item.Checked = len(pages) > 0
+		for _, p := range pages {
+			item.Checked = item.Checked && p.Compression == c
+		}
+		return item
+	}
+	colours := func(label string, m document.ColourMode) *fyne.MenuItem {
+		item := fyne.NewMenuItem(label, func() { fo.SetColourMode(m) })
+		item.Disabled = len(pages) == 0
+		item.Checked = len(pages) > 0
+		for _, p := range pages {
+			item.Checked = item.Checked && p.ColourMode == m
+		}
+		return item
+	}
+	menu := fyne.NewMenu("",
+		colours("Default Colours", document.DefaultColours),
+		colours("Keep Colours", document.KeepColours),
+		colours("Grayscale", document.Grayscale),
+		colours("Black & White Document", document.BlackAndWhite),
+		fyne.NewMenuItemSeparator(),
+		compression("Default Compression", document.DefaultCompression),
+		compression("Lossy Compression (JPEG)", document.LossyCompression),
+		compression("Lossless Compression", document.LosslessCompression),
+	)
+	showMenuBelow(fo.image, menu)
+}
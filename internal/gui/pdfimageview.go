@@ -14,16 +14,25 @@ import (
 	"fyne.io/fyne/v2/widget"
 	p4p "github.com/pic4pdf/lib-p4p"
 	"golang.org/x/image/draw"
+
+	"github.com/pic4pdf/pic4pdf/internal/document"
+	"github.com/pic4pdf/pic4pdf/internal/textpage"
 )
 
 type PDFImageView struct {
 	widget.BaseWidget
 
-	imgOpts  p4p.ImageOptions
-	minSize  fyne.Size
-	imgData  image.Image
-	unit     p4p.Unit
-	pageSize p4p.PageSize
+	imgOpts p4p.ImageOptions
+	minSize fyne.Size
+	imgData image.Image
+	// Page colour, defaults to white.
+	background color.Color
+	// Text page shown instead of imgData if hasText is set.
+	text       document.Text
+	textMargin float64
+	hasText    bool
+	unit       p4p.Unit
+	pageSize   p4p.PageSize
 	// Max image size in pixels, for rendering optimization
 	maxImgW int
 	maxImgH int
@@ -48,7 +57,14 @@ func (iv *PDFImageView) getConvPageSize() (w, h float64) {
 func (iv *PDFImageView) rerenderImage() {
 	fmt.Printf("Rerender (rand ID: %02x)\n", rand.Intn(0xFF))
 	iv.lock.Lock()
+	if iv.hasText {
+		iv.renderText()
+		iv.lock.Unlock()
+		return
+	}
 	if iv.imgData == nil {
+		// Empty page.
+		iv.img = nil
 		iv.lock.Unlock()
 		return
 	}
@@ -94,6 +110,20 @@ func (iv *PDFImageView) rerenderImage() {
 	iv.lock.Unlock()
 }
 
+// Renders the text page onto the whole page. Requires iv.lock to be locked!
+func (iv *PDFImageView) renderText() {
+	pt := iv.pageSize.Convert(p4p.Point)
+	scale := math.Min(float64(iv.maxImgW)/pt.W, float64(iv.maxImgH)/pt.H)
+	bg := iv.background
+	if bg == nil {
+		bg = color.White
+	}
+	lines, _ := textpage.Layout(iv.text, pt.W, pt.H, iv.textMargin)
+	iv.img = canvas.NewImageFromImage(textpage.Render(lines, pt.W, pt.H, scale, bg))
+	iv.imgX, iv.imgY = 0, 0
+	iv.imgW, iv.imgH = iv.getConvPageSize()
+}
+
 func NewPDFImageView(unit p4p.Unit, pageSize p4p.PageSize) *PDFImageView {
 	iv := &PDFImageView{
 		desc:     widget.NewLabel(""),
@@ -161,6 +191,50 @@ func (iv *PDFImageView) SetImage(img image.Image) {
 	iv.Refresh()
 }
 
+// Sets the page colour.
+func (iv *PDFImageView) SetBackground(c color.Color) {
+	iv.lock.Lock()
+	if iv.background == c {
+		iv.lock.Unlock()
+		return
+	}
+	iv.background = c
+	hasText := iv.hasText
+	iv.lock.Unlock()
+	if hasText {
+		iv.rerenderImage()
+	}
+	iv.Refresh()
+}
+
+// Shows the text page t with margin in points instead of the image.
+func (iv *PDFImageView) SetText(t document.Text, margin float64) {
+	iv.lock.Lock()
+	if iv.hasText && iv.text == t && iv.textMargin == margin {
+		iv.lock.Unlock()
+		return
+	}
+	iv.text = t
+	iv.textMargin = margin
+	iv.hasText = true
+	iv.lock.Unlock()
+	iv.rerenderImage()
+	iv.Refresh()
+}
+
+// Shows the image again after SetText.
+func (iv *PDFImageView) ClearText() {
+	iv.lock.Lock()
+	if !iv.hasText {
+		iv.lock.Unlock()
+		return
+	}
+	iv.hasText = false
+	iv.lock.Unlock()
+	iv.rerenderImage()
+	iv.Refresh()
+}
+
 func (iv *PDFImageView) SetDescription(text string) {
 	iv.lock.Lock()
 	iv.desc.SetText(text)
@@ -176,7 +250,7 @@ func (iv *PDFImageView) ExtendBaseWidget(w fyne.Widget) {
 func (iv *PDFImageView) CreateRenderer() fyne.WidgetRenderer {
 	r := &pdfImageViewRenderer{
 		iv: iv,
-		bg: canvas.NewRectangle(color.RGBA{255, 255, 255, 255}),
+		bg: canvas.NewRectangle(color.White),
 	}
 	return r
 }
@@ -258,6 +332,13 @@ func (r *pdfImageViewRenderer) MinSize() fyne.Size {
 }
 
 func (r *pdfImageViewRenderer) Refresh() {
+	r.iv.lock.Lock()
+	bg := r.iv.background
+	r.iv.lock.Unlock()
+	if bg != nil && r.bg.FillColor != bg {
+		r.bg.FillColor = bg
+		r.bg.Refresh()
+	}
 }
 
 func (r *pdfImageViewRenderer) Objects() []fyne.CanvasObject {
@@ -3,31 +3,59 @@ package gui
 import (
 	"fmt"
 	"image"
-	"os"
-	"path/filepath"
+	"slices"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/widget"
 	p4p "github.com/pic4pdf/lib-p4p"
+
+	"github.com/pic4pdf/pic4pdf/internal/bilevel"
+	"github.com/pic4pdf/pic4pdf/internal/document"
+	"github.com/pic4pdf/pic4pdf/internal/imgload"
+	"github.com/pic4pdf/pic4pdf/internal/textpage"
 )
 
 type PDFPreview struct {
 	widget.BaseWidget
 
-	OnError  func(error)
-	Layout   p4p.Mode
-	Scale    float64
-	Unit     p4p.Unit
-	PageSize p4p.PageSize
+	OnError func(error)
+	// Called when anything affecting the output changes: pages or the
+	// options set through PDFPreview.
+	OnChanged func()
+	Layout    p4p.Mode
+	Scale     float64
+	Unit      p4p.Unit
+	PageSize  p4p.PageSize
+	// Margin of text pages in points.
+	Margin float64
+	// Colour mode of pages which don't set their own.
+	ColourMode document.ColourMode
 
 	Overview *FileOverview
 
 	imgs map[string]image.Image
+	// Versions of imgs with page settings applied, at most maxProcessed of
+	// them. processedKeys is ordered from least to most recently used.
+	processed     map[processedKey]image.Image
+	processedKeys []processedKey
 
 	list *widget.List
 }
 
-// Sets ow.OnSelected, OnUnselected and OnReorder!
+// Margin of text pages in points (20 mm).
+const DefaultMargin = 20 * float64(p4p.Millimeter)
+
+// Number of processed images kept, enough for the pages visible at once.
+// Each is as large as the image file decoded.
+const maxProcessed = 16
+
+type processedKey struct {
+	path       string
+	rotation   int
+	colourMode document.ColourMode
+}
+
+// Sets ow.OnSelected, OnUnselected, OnReorder and OnPagesChanged!
 func NewPDFPreview(ow *FileOverview, unit p4p.Unit, pageSize p4p.PageSize) *PDFPreview {
 	il := &PDFPreview{
 		Layout:   p4p.Fit,
@@ -35,6 +63,7 @@ func NewPDFPreview(ow *FileOverview, unit p4p.Unit, pageSize p4p.PageSize) *PDFP
 		Overview: ow,
 		Unit:     unit,
 		PageSize: pageSize,
+		Margin:   DefaultMargin,
 	}
 	il.ExtendBaseWidget(il)
 	return il
@@ -42,12 +71,12 @@ func NewPDFPreview(ow *FileOverview, unit p4p.Unit, pageSize p4p.PageSize) *PDFP
 
 func (il *PDFPreview) SetLayout(mode p4p.Mode) {
 	il.Layout = mode
-	il.Refresh()
+	il.changed()
 }
 
 func (il *PDFPreview) SetScale(sc float64) {
 	il.Scale = sc
-	il.Refresh()
+	il.changed()
 }
 
 func (il *PDFPreview) SetUnit(u p4p.Unit) {
@@ -57,12 +86,23 @@ func (il *PDFPreview) SetUnit(u p4p.Unit) {
 
 func (il *PDFPreview) SetPageSize(s p4p.PageSize) {
 	il.PageSize = s
-	il.Refresh()
+	il.changed()
+}
+
+func (il *PDFPreview) SetMargin(m float64) {
+	il.Margin = m
+	il.changed()
+}
+
+func (il *PDFPreview) SetColourMode(m document.ColourMode) {
+	il.ColourMode = m
+	il.changed()
 }
 
 func (il *PDFPreview) ExtendBaseWidget(w fyne.Widget) {
 	il.BaseWidget.ExtendBaseWidget(w)
 	il.imgs = make(map[string]image.Image)
+	il.processed = make(map[processedKey]image.Image)
 	il.list = widget.NewList(
 		func() int {
 			return il.Overview.NumSelected()
@@ -73,16 +113,32 @@ func (il *PDFPreview) ExtendBaseWidget(w fyne.Widget) {
 			return iv
 		},
 		func(id widget.ListItemID, obj fyne.CanvasObject) {
-			sel := il.Overview.Selected()
+			pages := document.Printed(il.Overview.Pages())
 			iv := obj.(*PDFImageView)
-			if id < len(sel) {
-				iv.SetDescription(fmt.Sprintf("%v/%v (%v)", id+1, len(sel), filepath.Base(sel[id])))
-				if img, ok := il.imgs[sel[id]]; ok {
+			if id < len(pages) {
+				desc := fmt.Sprintf("%v/%v (%v)", id+1, len(pages), pageName(pages[id]))
+				if pages[id].Kind == document.TextPage {
+					pt := il.PageSize.Convert(p4p.Point)
+					if _, fits := textpage.Layout(pages[id].Text, pt.W, pt.H, il.Margin); !fits {
+						desc += " - Text doesn't fit on the page"
+					}
+					if missing := textpage.Missing(pages[id].Text); len(missing) > 0 {
+						desc += fmt.Sprintf(" - The font lacks %q", string(missing))
+					}
+				}
+				iv.SetDescription(desc)
+				if pages[id].Kind == document.TextPage {
+					iv.SetText(pages[id].Text, il.Margin)
+				} else {
+					iv.ClearText()
+				}
+				if img := il.pageImage(pages[id]); img != nil || pages[id].Kind != document.ImagePage {
 					iv.SetOptions(p4p.ImageOptions{
 						Mode:  il.Layout,
 						Scale: il.Scale,
 					})
 					iv.SetImage(img)
+					iv.SetBackground(pages[id].Colour())
 					iv.SetParams(il.Unit, il.PageSize)
 				}
 			}
@@ -93,31 +149,75 @@ func (il *PDFPreview) ExtendBaseWidget(w fyne.Widget) {
 	}
 
 	il.Overview.OnSelected = func(path string) {
-		f, err := os.Open(path)
+		img, err := imgload.Load(path)
 		if err != nil {
 			if il.OnError != nil {
 				il.OnError(err)
 			}
 			return
 		}
-		defer f.Close()
-		img, _, err := image.Decode(f)
-		if err != nil {
-			if il.OnError != nil {
-				il.OnError(fmt.Errorf("invalid image '%v': %w", filepath.Base(path), err))
-			}
-			return
-		}
 		il.imgs[path] = img
 		il.list.Refresh()
+		il.changed()
 	}
 	il.Overview.OnUnselected = func(path string) {
 		delete(il.imgs, path)
+		il.processedKeys = slices.DeleteFunc(il.processedKeys, func(k processedKey) bool {
+			if k.path == path {
+				delete(il.processed, k)
+				return true
+			}
+			return false
+		})
 		il.list.Refresh()
+		il.changed()
 	}
 	il.Overview.OnReorder = func() {
 		il.list.Refresh()
+		il.changed()
+	}
+	il.Overview.OnPagesChanged = func() {
+		il.list.Refresh()
+		il.changed()
+	}
+}
+
+func (il *PDFPreview) changed() {
+	il.Refresh()
+	if il.OnChanged != nil {
+		il.OnChanged()
+	}
+}
+
+// Returns the image of page with its settings applied, or nil if it is not
+// loaded.
+func (il *PDFPreview) pageImage(page document.Page) image.Image {
+	img, ok := il.imgs[page.Path]
+	mode := page.Colours(il.ColourMode)
+	if !ok || (page.Rotation == 0 && mode == document.KeepColours) {
+		return img
+	}
+	key := processedKey{path: page.Path, rotation: page.Rotation, colourMode: mode}
+	if r, ok := il.processed[key]; ok {
+		i := slices.Index(il.processedKeys, key)
+		il.processedKeys = append(slices.Delete(il.processedKeys, i, i+1), key)
+		return r
+	}
+	r := imgload.Rotate(img, page.Rotation)
+	switch mode {
+	case document.Grayscale:
+		r = bilevel.Gray(r)
+	case document.BlackAndWhite:
+		// As exported, but at full resolution.
+		r = bilevel.Threshold(r)
+	}
+	if len(il.processedKeys) == maxProcessed {
+		delete(il.processed, il.processedKeys[0])
+		il.processedKeys = slices.Delete(il.processedKeys, 0, 1)
 	}
+	il.processed[key] = r
+	il.processedKeys = append(il.processedKeys, key)
+	return r
 }
 
 func (il *PDFPreview) CreateRenderer() fyne.WidgetRenderer {
@@ -0,0 +1,39 @@
+package gui
+
+import (
+	"fmt"
+	"image"
+	"testing"
+
+	p4p "github.com/pic4pdf/lib-p4p"
+
+	"github.com/pic4pdf/pic4pdf/internal/document"
+)
+
+func TestProcessedImages(t *testing.T) {
+	pv := NewPDFPreview(newTestOverview(t, ""), p4p.Millimeter, p4p.A4())
+	page := func(i int) document.Page {
+		return document.Page{Path: fmt.Sprintf("/pictures/%v.jpg", i), Rotation: 90}
+	}
+	for i := 0; i < maxProcessed+4; i++ {
+		pv.imgs[page(i).Path] = image.NewRGBA(image.Rect(0, 0, 3, 2))
+		if img := pv.pageImage(page(i)); img.Bounds().Dx() != 2 {
+			t.Fatalf("page %v: got image of %v, want it rotated", i, img.Bounds())
+		}
+		// Keeps the first page, which is used all along.
+		pv.pageImage(page(0))
+	}
+	if len(pv.processed) != maxProcessed || len(pv.processedKeys) != maxProcessed {
+		t.Errorf("%v processed images kept, want %v", len(pv.processed), maxProcessed)
+	}
+	for i, want := range map[int]bool{0: true, 1: false, 4: false, 5: true, maxProcessed + 3: true} {
+		if _, ok := pv.processed[processedKey{page(i).Path, 90, document.KeepColours}]; ok != want {
+			t.Errorf("page %v kept: %v, want %v", i, ok, want)
+		}
+	}
+
+	pv.Overview.OnUnselected(page(0).Path)
+	if len(pv.processed) != maxProcessed-1 || len(pv.processedKeys) != maxProcessed-1 {
+		t.Errorf("%v processed images kept after unselecting one, want %v", len(pv.processed), maxProcessed-1)
+	}
+}
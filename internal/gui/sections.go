@@ -0,0 +1,303 @@
+package gui
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/pic4pdf/pic4pdf/internal/document"
+	"github.com/pic4pdf/pic4pdf/internal/paste"
+)
+
+// Returns the [start, end) ranges of all sections, each starting with its
+// SectionStart.
+func (fo *FileOverview) sectionRanges() [][2]int {
+	var res [][2]int
+	for i, p := range fo.pages {
+		if p.Kind != document.SectionStart {
+			continue
+		}
+		if n := len(res); n > 0 {
+			res[n-1][1] = i
+		}
+		res = append(res, [2]int{i, len(fo.pages)})
+	}
+	return res
+}
+
+// Returns the number of pages in the section starting at idx.
+func (fo *FileOverview) sectionSize(idx int) int {
+	n := 0
+	for _, p := range fo.pages[idx+1:] {
+		if p.Kind == document.SectionStart {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+// Returns the selected pages in order, including all pages of selected
+// sections.
+func (fo *FileOverview) selectedPages() []*document.Page {
+	var res []*document.Page
+	inSelected := false
+	for _, p := range fo.pages {
+		_, ok := fo.selected[p]
+		if p.Kind == document.SectionStart {
+			inSelected = ok
+		}
+		if ok || inSelected {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// Returns the selected section headers in order.
+func (fo *FileOverview) selectedSections() []*document.Page {
+	var res []*document.Page
+	for _, p := range fo.pages {
+		if _, ok := fo.selected[p]; ok && p.Kind == document.SectionStart {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// Collapses the section started by header, or expands it if collapsed.
+// Pages hidden by collapsing are unselected.
+func (fo *FileOverview) ToggleCollapsed(header *document.Page) {
+	if _, ok := fo.collapsed[header.ID]; ok {
+		delete(fo.collapsed, header.ID)
+	} else {
+		fo.collapsed[header.ID] = struct{}{}
+	}
+	fo.unselectHidden()
+}
+
+// Collapses or expands all sections.
+func (fo *FileOverview) SetAllCollapsed(collapsed bool) {
+	fo.collapsed = make(map[int]struct{})
+	if collapsed {
+		for _, p := range fo.pages {
+			if p.Kind == document.SectionStart {
+				fo.collapsed[p.ID] = struct{}{}
+			}
+		}
+	}
+	fo.unselectHidden()
+}
+
+func (fo *FileOverview) unselectHidden() {
+	fo.refreshList()
+	visible := make(map[*document.Page]struct{}, len(fo.rows))
+	for _, i := range fo.rows {
+		visible[fo.pages[i]] = struct{}{}
+	}
+	for p := range fo.selected {
+		if _, ok := visible[p]; !ok {
+			delete(fo.selected, p)
+		}
+	}
+	if _, ok := visible[fo.anchor]; !ok {
+		fo.anchor = nil
+	}
+	fo.refreshButtons()
+	fo.list.Refresh()
+}
+
+// Returns the title of the section containing the page at idx, or "" if it
+// is not in a section.
+func (fo *FileOverview) sectionTitle(idx int) string {
+	for i := idx - 1; i >= 0; i-- {
+		if fo.pages[i].Kind == document.SectionStart {
+			return fo.pages[i].Title
+		}
+	}
+	return ""
+}
+
+// Moves the selected pages together into a new section named title, placed
+// where the first of them was. Pages following them which were in the same
+// section as the first stay in a section with the previous title.
+//
+// An empty title is replaced by "New Section", as an untitled SectionStart
+// would end a section rather than start one.
+func (fo *FileOverview) NewSection(title string) {
+	if title == "" {
+		title = "New Section"
+	}
+	var sel []*document.Page
+	first := -1
+	for i, p := range fo.pages {
+		if _, ok := fo.selected[p]; ok && p.Kind != document.SectionStart {
+			if first == -1 {
+				first = i
+			}
+			sel = append(sel, p)
+		}
+	}
+	if len(sel) == 0 {
+		return
+	}
+	prevTitle := fo.sectionTitle(first)
+	header := &document.Page{ID: fo.newID(), Kind: document.SectionStart, Title: title}
+	res := make([]*document.Page, 0, len(fo.pages)+2)
+	res = append(res, fo.pages[:first]...)
+	if n := len(res); n > 0 && res[n-1].Kind == document.SectionStart {
+		// The section of the first page would become empty; its pages
+		// after the new section continue it.
+		res = res[:n-1]
+	}
+	res = append(res, header)
+	res = append(res, sel...)
+	needsEnd := true
+	for _, p := range fo.pages[first:] {
+		if _, ok := fo.selected[p]; ok && p.Kind != document.SectionStart {
+			continue
+		}
+		if needsEnd && p.Kind != document.SectionStart {
+			res = append(res, &document.Page{ID: fo.newID(), Kind: document.SectionStart, Title: prevTitle})
+		}
+		needsEnd = false
+		res = append(res, p)
+	}
+	fo.selected = map[*document.Page]struct{}{header: {}}
+	fo.anchor = header
+	fo.setOrder("New Section", res)
+}
+
+// Renames the selected section if it is the only one.
+func (fo *FileOverview) RenameSection(title string) {
+	sel := fo.selectedSections()
+	if len(sel) != 1 || sel[0].Title == title {
+		return
+	}
+	before := fo.Pages()
+	sel[0].Title = title
+	fo.record("Rename Section", before)
+	fo.refreshList()
+	if fo.OnPagesChanged != nil {
+		fo.OnPagesChanged()
+	}
+}
+
+// Removes the selected section headers, or all if all is set, keeping their
+// pages.
+func (fo *FileOverview) Ungroup(all bool) {
+	res := make([]*document.Page, 0, len(fo.pages))
+	for _, p := range fo.pages {
+		if _, ok := fo.selected[p]; (ok || all) && p.Kind == document.SectionStart {
+			continue
+		}
+		res = append(res, p)
+	}
+	fo.setOrder("Ungroup", res)
+}
+
+type SectionKey int
+
+const (
+	// Name of the folder containing the file.
+	SectionsByFolder SectionKey = iota
+	// Start of the file name up to the first digit, e.g. "Holiday" for
+	// "Holiday_012.jpg".
+	SectionsByPrefix
+)
+
+// Returns the section title of an image page, or "" if it has none.
+func sectionName(path string, key SectionKey) string {
+	if name, ok := paste.Name(path); ok {
+		if key == SectionsByFolder {
+			return "Pasted"
+		}
+		path = name
+	}
+	if key == SectionsByFolder {
+		return filepath.Base(filepath.Dir(path))
+	}
+	name := filepath.Base(path)
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	if i := strings.IndexFunc(name, unicode.IsDigit); i != -1 {
+		name = name[:i]
+	}
+	if name = strings.TrimRight(name, " _-.("); name == "" {
+		return filepath.Base(path)
+	}
+	return name
+}
+
+// Replaces all sections by sections of consecutive image pages with the
+// same key. Other pages are added to the section before them.
+func (fo *FileOverview) GroupBy(key SectionKey) {
+	res := make([]*document.Page, 0, len(fo.pages))
+	title, started := "", false
+	for _, p := range fo.pages {
+		switch p.Kind {
+		case document.SectionStart:
+			continue
+		case document.ImagePage:
+			if t := sectionName(p.Path, key); !started || t != title {
+				res = append(res, &document.Page{ID: fo.newID(), Kind: document.SectionStart, Title: t})
+				title, started = t, true
+			}
+		}
+		res = append(res, p)
+	}
+	fo.setOrder("Group Pages", res)
+}
+
+// Asks for a section title, starting with title, and calls fn with it
+// unless cancelled.
+func (fo *FileOverview) showTitleDialog(dlgTitle, title string, fn func(string)) {
+	w := windowOf(fo)
+	if w == nil {
+		return
+	}
+	entry := widget.NewEntry()
+	entry.SetText(title)
+	d := dialog.NewForm(dlgTitle, "OK", "Cancel", []*widget.FormItem{
+		widget.NewFormItem("Title", entry),
+	}, func(ok bool) {
+		if ok {
+			fn(strings.TrimSpace(entry.Text))
+		}
+	}, w)
+	d.Resize(fyne.NewSize(360, 0).Max(d.MinSize()))
+	d.Show()
+	w.Canvas().Focus(entry)
+}
+
+func (fo *FileOverview) showSectionsMenu() {
+	sections := fo.selectedSections()
+	newSection := fyne.NewMenuItem("New Section...", func() {
+		fo.showTitleDialog("New Section", "", fo.NewSection)
+	})
+	newSection.Disabled = len(fo.selected) == len(sections)
+	rename := fyne.NewMenuItem("Rename Section...", func() {
+		if sel := fo.selectedSections(); len(sel) == 1 {
+			fo.showTitleDialog("Rename Section", sel[0].Title, fo.RenameSection)
+		}
+	})
+	rename.Disabled = len(sections) != 1
+	ungroup := fyne.NewMenuItem("Ungroup", func() { fo.Ungroup(false) })
+	ungroup.Disabled = len(sections) == 0
+	menu := fyne.NewMenu("",
+		newSection,
+		rename,
+		ungroup,
+		fyne.NewMenuItem("Ungroup All", func() { fo.Ungroup(true) }),
+		fyne.NewMenuItemSeparator(),
+		fyne.NewMenuItem("Group by Folder", func() { fo.GroupBy(SectionsByFolder) }),
+		fyne.NewMenuItem("Group by Name Prefix", func() { fo.GroupBy(SectionsByPrefix) }),
+		fyne.NewMenuItemSeparator(),
+		fyne.NewMenuItem("Collapse All", func() { fo.SetAllCollapsed(true) }),
+		fyne.NewMenuItem("Expand All", func() { fo.SetAllCollapsed(false) }),
+	)
+	showMenuBelow(fo.sections, menu)
+}
@@ -0,0 +1,89 @@
+package gui
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/pic4pdf/pic4pdf/internal/document"
+)
+
+func TestSectionRanges(t *testing.T) {
+	fo := newTestOverview(t, "x [S1] a b [S2] [S3] c")
+	if got, want := fo.sectionRanges(), [][2]int{{1, 4}, {4, 5}, {5, 7}}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := newTestOverview(t, "a b").sectionRanges(); got != nil {
+		t.Errorf("without sections: got %v, want none", got)
+	}
+}
+
+func TestNewSection(t *testing.T) {
+	tests := []struct {
+		pages    string
+		selected []string
+		want     string
+	}{
+		{"a b c d", []string{"b", "c"}, "a [New] b c [] d"},
+		{"a b c d", []string{"c", "d"}, "a b [New] c d"},
+		{"a b [S] c", []string{"a", "b"}, "[New] a b [S] c"},
+		{"[S] a b c", []string{"b"}, "[S] a [New] b [S] c"},
+		{"[S] a b c", []string{"a", "c"}, "[New] a c [S] b"},
+		{"[S] a b [T] c d", []string{"b", "c"}, "[S] a [New] b c [T] d"},
+		{"[S] a [T] b", []string{"a", "[T]", "b"}, "[New] a b [T]"},
+		{"[S] a", []string{"[S]"}, "[S] a"},
+	}
+	for _, tt := range tests {
+		fo := newTestOverview(t, tt.pages)
+		selectPages(fo, tt.selected...)
+		fo.NewSection("New")
+		if got := order(fo.pages); got != tt.want {
+			t.Errorf("%v, %v selected: got %v, want %v", tt.pages, tt.selected, got, tt.want)
+		}
+	}
+
+	fo := newTestOverview(t, "[S] a b")
+	selectPages(fo, "b")
+	fo.NewSection("")
+	if got, want := order(fo.pages), "[S] a [New Section] b"; got != want {
+		t.Errorf("without a title: got %v, want %v", got, want)
+	}
+	if sel := fo.selectedSections(); len(sel) != 1 || sel[0] != fo.pages[2] {
+		t.Errorf("selected %v, want the new section", sel)
+	}
+}
+
+func TestGroupBy(t *testing.T) {
+	fo := newTestOverview(t, "")
+	for _, p := range []string{"/a/Holiday_01.jpg", "/a/Holiday_02.jpg", "", "/b/Holiday 3.jpg", "/b/IMG_4.jpg", "/a/123.jpg"} {
+		page := &document.Page{ID: fo.newID(), Path: p}
+		if p == "" {
+			page.Kind = document.BlankPage
+		}
+		fo.pages = append(fo.pages, page)
+	}
+	// Existing sections are replaced.
+	fo.pages = slices.Insert(fo.pages, 3, &document.Page{ID: fo.newID(), Kind: document.SectionStart, Title: "Old"})
+	titles := func() (res []string) {
+		for _, p := range fo.pages {
+			if p.Kind == document.SectionStart {
+				res = append(res, p.Title)
+			} else {
+				res = append(res, fo.sectionTitle(fo.indexOfID(p.ID)))
+			}
+		}
+		return res
+	}
+	fo.GroupBy(SectionsByFolder)
+	if got, want := titles(), []string{"a", "a", "a", "a", "b", "b", "b", "a", "a"}; !slices.Equal(got, want) {
+		t.Errorf("by folder: got %q, want %q", got, want)
+	}
+	fo.GroupBy(SectionsByPrefix)
+	if got, want := titles(), []string{"Holiday", "Holiday", "Holiday", "Holiday", "Holiday", "IMG", "IMG", "123.jpg", "123.jpg"}; !slices.Equal(got, want) {
+		t.Errorf("by prefix: got %q, want %q", got, want)
+	}
+	fo.History.Undo()
+	fo.History.Undo()
+	if got, want := titles(), []string{"", "", "", "Old", "Old", "Old", "Old"}; !slices.Equal(got, want) {
+		t.Errorf("after undo: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,65 @@
+package gui
+
+import (
+	"strconv"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/pic4pdf/pic4pdf/internal/document"
+	"github.com/pic4pdf/pic4pdf/internal/textpage"
+)
+
+var alignNames = []string{"Left", "Center", "Right"}
+
+// Lets the user edit t and calls fn with the result unless cancelled.
+func (fo *FileOverview) showTextDialog(title string, t document.Text, fn func(document.Text)) {
+	w := windowOf(fo)
+	if w == nil {
+		return
+	}
+	heading := widget.NewEntry()
+	heading.SetText(t.Heading)
+	body := widget.NewMultiLineEntry()
+	body.Wrapping = fyne.TextWrapWord
+	body.SetMinRowsVisible(8)
+	body.SetText(t.Body)
+	fontSize := widget.NewEntry()
+	fontSize.SetText(strconv.FormatFloat(t.FontSize, 'f', -1, 64))
+	fontSize.Validator = func(s string) error {
+		_, err := strconv.ParseFloat(s, 64)
+		return err
+	}
+	align := widget.NewRadioGroup(alignNames, nil)
+	align.Horizontal = true
+	align.Required = true
+	align.SetSelected(alignNames[t.Align])
+
+	d := dialog.NewForm(title, "OK", "Cancel", []*widget.FormItem{
+		widget.NewFormItem("Heading", heading),
+		widget.NewFormItem("Text", body),
+		widget.NewFormItem("Font Size", fontSize),
+		widget.NewFormItem("Alignment", align),
+	}, func(ok bool) {
+		if !ok {
+			return
+		}
+		res := document.Text{
+			Heading: heading.Text,
+			Body:    body.Text,
+		}
+		res.FontSize, _ = strconv.ParseFloat(fontSize.Text, 64)
+		if res.FontSize <= 0 {
+			res.FontSize = textpage.DefaultFontSize
+		}
+		for i, name := range alignNames {
+			if name == align.Selected {
+				res.Align = document.Align(i)
+			}
+		}
+		fn(res)
+	}, w)
+	d.Resize(fyne.NewSize(480, 0).Max(d.MinSize()))
+	d.Show()
+}
@@ -0,0 +1,211 @@
+// Package history implements a bounded undo/redo history.
+//
+// All methods may be called on a nil *History, in which case nothing is
+// recorded.
+package history
+
+type entry struct {
+	name  string
+	undo  func()
+	redo  func()
+	drop  func()
+	merge bool
+}
+
+type History struct {
+	// Maximum number of steps that can be undone.
+	Max int
+	// Called whenever the history changes.
+	OnChanged func()
+
+	undo     []*entry
+	redo     []*entry
+	applying bool
+	// Entries collected by Group, nil if not grouping.
+	group []*entry
+}
+
+func New(max int) *History {
+	return &History{Max: max}
+}
+
+func (h *History) changed() {
+	if h.OnChanged != nil {
+		h.OnChanged()
+	}
+}
+
+func dropAll(ents []*entry) {
+	for _, e := range ents {
+		if e.drop != nil {
+			e.drop()
+		}
+	}
+}
+
+func (h *History) push(e *entry) {
+	if h.group != nil {
+		h.group = append(h.group, e)
+		return
+	}
+	dropAll(h.redo)
+	h.redo = nil
+	h.undo = append(h.undo, e)
+	if h.Max > 0 && len(h.undo) > h.Max {
+		n := len(h.undo) - h.Max
+		dropAll(h.undo[:n])
+		h.undo = append([]*entry(nil), h.undo[n:]...)
+	}
+	h.changed()
+}
+
+// Records a step which can be undone.
+//
+// drop, which may be nil, is called once the step can neither be undone nor
+// redone anymore.
+//
+// Does nothing while undoing or redoing, so callers don't need to tell
+// changes made by the user from changes made by undo and redo.
+func (h *History) Add(name string, undo, redo, drop func()) {
+	if h == nil || h.applying {
+		return
+	}
+	h.push(&entry{name: name, undo: undo, redo: redo, drop: drop})
+}
+
+// Like Add, but merges with the previous step if it was also added by
+// AddMerge with the same name. Useful for changes made on every keystroke.
+func (h *History) AddMerge(name string, undo, redo, drop func()) {
+	if h == nil || h.applying {
+		return
+	}
+	ents := h.undo
+	if h.group != nil {
+		ents = h.group
+	}
+	if len(ents) > 0 && len(h.redo) == 0 {
+		if last := ents[len(ents)-1]; last.merge && last.name == name {
+			last.redo = redo
+			if prevDrop := last.drop; drop != nil || prevDrop != nil {
+				last.drop = func() {
+					if prevDrop != nil {
+						prevDrop()
+					}
+					if drop != nil {
+						drop()
+					}
+				}
+			}
+			return
+		}
+	}
+	h.push(&entry{name: name, undo: undo, redo: redo, drop: drop, merge: true})
+}
+
+// Records all steps added while running fn as a single step.
+func (h *History) Group(name string, fn func()) {
+	if h == nil || h.applying || h.group != nil {
+		// Nested groups are part of the outer group.
+		fn()
+		return
+	}
+	h.group = []*entry{}
+	fn()
+	ents := h.group
+	h.group = nil
+	switch len(ents) {
+	case 0:
+		return
+	case 1:
+		ents[0].name = name
+		ents[0].merge = false
+		h.push(ents[0])
+		return
+	}
+	h.push(&entry{
+		name: name,
+		undo: func() {
+			for i := len(ents) - 1; i >= 0; i-- {
+				ents[i].undo()
+			}
+		},
+		redo: func() {
+			for _, e := range ents {
+				e.redo()
+			}
+		},
+		drop: func() {
+			dropAll(ents)
+		},
+	})
+}
+
+// Reports whether an undo or redo is in progress.
+func (h *History) Applying() bool {
+	return h != nil && h.applying
+}
+
+func (h *History) CanUndo() bool {
+	return h != nil && len(h.undo) > 0
+}
+
+func (h *History) CanRedo() bool {
+	return h != nil && len(h.redo) > 0
+}
+
+// Returns the name of the step Undo would revert, or "".
+func (h *History) UndoName() string {
+	if !h.CanUndo() {
+		return ""
+	}
+	return h.undo[len(h.undo)-1].name
+}
+
+// Returns the name of the step Redo would repeat, or "".
+func (h *History) RedoName() string {
+	if !h.CanRedo() {
+		return ""
+	}
+	return h.redo[len(h.redo)-1].name
+}
+
+// Reverts the last step.
+func (h *History) Undo() {
+	if !h.CanUndo() || h.applying {
+		return
+	}
+	e := h.undo[len(h.undo)-1]
+	h.undo = h.undo[:len(h.undo)-1]
+	e.merge = false
+	h.applying = true
+	e.undo()
+	h.applying = false
+	h.redo = append(h.redo, e)
+	h.changed()
+}
+
+// Repeats the last undone step.
+func (h *History) Redo() {
+	if !h.CanRedo() || h.applying {
+		return
+	}
+	e := h.redo[len(h.redo)-1]
+	h.redo = h.redo[:len(h.redo)-1]
+	h.applying = true
+	e.redo()
+	h.applying = false
+	h.undo = append(h.undo, e)
+	h.changed()
+}
+
+// Removes all steps.
+func (h *History) Clear() {
+	if h == nil {
+		return
+	}
+	dropAll(h.undo)
+	dropAll(h.redo)
+	h.undo = nil
+	h.redo = nil
+	h.changed()
+}
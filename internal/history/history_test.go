@@ -0,0 +1,173 @@
+package history
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// A value changed in steps recorded in a History.
+type value struct {
+	h *History
+	v int
+	// Names of the dropped steps.
+	dropped []string
+}
+
+// Sets the value to n. Undo and redo call set too, whose steps are ignored.
+func (v *value) set(n int) {
+	old := v.v
+	v.v = n
+	name := fmt.Sprint(n)
+	v.h.Add(name, func() { v.set(old) }, func() { v.set(n) }, func() { v.dropped = append(v.dropped, name) })
+}
+
+func (v *value) setMerge(n int) {
+	old := v.v
+	v.v = n
+	name := fmt.Sprint(n)
+	v.h.AddMerge("type", func() { v.v = old }, func() { v.v = n }, func() { v.dropped = append(v.dropped, name) })
+}
+
+func TestUndoRedo(t *testing.T) {
+	v := &value{h: New(0)}
+	for i := 1; i <= 3; i++ {
+		v.set(i)
+	}
+	v.h.Undo()
+	v.h.Undo()
+	if v.v != 1 || v.h.UndoName() != "1" || v.h.RedoName() != "2" {
+		t.Fatalf("after undoing twice: value %v, undo %q, redo %q; want 1, \"1\", \"2\"", v.v, v.h.UndoName(), v.h.RedoName())
+	}
+	v.h.Redo()
+	if v.v != 2 || v.h.RedoName() != "3" {
+		t.Fatalf("after redo: value %v, redo %q; want 2, \"3\"", v.v, v.h.RedoName())
+	}
+	// A new step discards the steps which could be redone.
+	v.set(5)
+	if v.h.CanRedo() || !slices.Equal(v.dropped, []string{"3"}) {
+		t.Errorf("after a new step: can redo %v, dropped %v; want false, [3]", v.h.CanRedo(), v.dropped)
+	}
+	for v.h.CanUndo() {
+		v.h.Undo()
+	}
+	if v.v != 0 || v.h.UndoName() != "" || v.h.RedoName() != "1" {
+		t.Errorf("after undoing all: value %v, undo %q, redo %q; want 0, \"\", \"1\"", v.v, v.h.UndoName(), v.h.RedoName())
+	}
+	v.h.Undo()
+	v.h.Clear()
+	if v.h.CanRedo() || len(v.dropped) != 4 {
+		t.Errorf("after clear: can redo %v, dropped %v", v.h.CanRedo(), v.dropped)
+	}
+}
+
+func TestMax(t *testing.T) {
+	v := &value{h: New(2)}
+	changes := 0
+	v.h.OnChanged = func() { changes++ }
+	for i := 1; i <= 4; i++ {
+		v.set(i)
+	}
+	if !slices.Equal(v.dropped, []string{"1", "2"}) || changes != 4 {
+		t.Errorf("dropped %v with %v changes, want [1 2] with 4", v.dropped, changes)
+	}
+	v.h.Undo()
+	v.h.Undo()
+	if v.v != 2 || v.h.CanUndo() {
+		t.Errorf("after undoing all: value %v, can undo %v; want 2, false", v.v, v.h.CanUndo())
+	}
+}
+
+func TestGroup(t *testing.T) {
+	v := &value{h: New(0)}
+	v.set(1)
+	v.h.Group("all", func() {
+		v.set(2)
+		// Nested groups are part of the outer one.
+		v.h.Group("inner", func() {
+			v.set(3)
+		})
+		v.set(4)
+	})
+	if v.h.UndoName() != "all" {
+		t.Errorf("step is %q, want \"all\"", v.h.UndoName())
+	}
+	v.h.Undo()
+	if v.v != 1 || v.h.UndoName() != "1" {
+		t.Errorf("after undo: value %v, undo %q; want 1, \"1\"", v.v, v.h.UndoName())
+	}
+	v.h.Redo()
+	if v.v != 4 {
+		t.Errorf("after redo: value %v, want 4", v.v)
+	}
+
+	// Empty groups aren't steps, and single steps get the group's name.
+	v.h.Group("empty", func() {})
+	v.h.Group("single", func() { v.set(5) })
+	if v.h.UndoName() != "single" {
+		t.Errorf("step is %q, want \"single\"", v.h.UndoName())
+	}
+	v.h.Undo()
+	v.h.Undo()
+	v.set(6)
+	if !slices.Equal(v.dropped, []string{"5", "2", "3", "4"}) {
+		t.Errorf("dropped %v, want [5 2 3 4]", v.dropped)
+	}
+}
+
+func TestAddMerge(t *testing.T) {
+	v := &value{h: New(0)}
+	v.set(1)
+	for i := 2; i <= 4; i++ {
+		v.setMerge(i)
+	}
+	v.h.Undo()
+	if v.v != 1 || v.h.UndoName() != "1" {
+		t.Fatalf("after undo: value %v, undo %q; want 1, \"1\"", v.v, v.h.UndoName())
+	}
+	v.h.Redo()
+	if v.v != 4 {
+		t.Fatalf("after redo: value %v, want 4", v.v)
+	}
+	// Steps undone once aren't merged with anymore.
+	v.setMerge(5)
+	v.h.Undo()
+	if v.v != 4 {
+		t.Errorf("after undoing a step after redo: value %v, want 4", v.v)
+	}
+	// Merging would lose the step which can be redone.
+	v.setMerge(6)
+	v.set(7)
+	v.h.Undo()
+	v.setMerge(8)
+	v.h.Undo()
+	if v.v != 6 {
+		t.Errorf("after undoing a step while one could be redone: value %v, want 6", v.v)
+	}
+	// Other steps end the merging.
+	v.h.Redo()
+	v.set(9)
+	v.setMerge(10)
+	v.h.Undo()
+	if v.v != 9 {
+		t.Errorf("after undoing a step following another: value %v, want 9", v.v)
+	}
+	v.h.Clear()
+	if want := []string{"5", "7", "1", "2", "3", "4", "6", "8", "9", "10"}; !slices.Equal(v.dropped, want) {
+		t.Errorf("dropped %v, want %v", v.dropped, want)
+	}
+}
+
+func TestNil(t *testing.T) {
+	var h *History
+	h.Add("a", nil, nil, nil)
+	h.AddMerge("a", nil, nil, nil)
+	ran := false
+	h.Group("a", func() { ran = true })
+	h.Undo()
+	h.Redo()
+	h.Clear()
+	if !ran || h.CanUndo() || h.CanRedo() || h.Applying() || h.UndoName() != "" {
+		t.Error("nil History recorded something")
+	}
+}
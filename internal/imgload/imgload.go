@@ -0,0 +1,75 @@
+// Package imgload decodes the images pages are made from.
+package imgload
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pic4pdf/pic4pdf/internal/archive"
+	"github.com/pic4pdf/pic4pdf/internal/document"
+	"github.com/pic4pdf/pic4pdf/internal/raw"
+)
+
+// Decodes the image at path, which may also lead into an archive.
+//
+// Camera RAW files are decoded from their embedded JPEG preview.
+func Load(path string) (image.Image, error) {
+	f, err := archive.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var img image.Image
+	if raw.IsRaw(path) {
+		img, err = decodeRaw(f)
+	} else {
+		img, _, err = image.Decode(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid image '%v': %w", filepath.Base(path), err)
+	}
+	return img, nil
+}
+
+func decodeRaw(f io.Reader) (image.Image, error) {
+	if osf, ok := f.(*os.File); ok {
+		st, err := osf.Stat()
+		if err != nil {
+			return nil, err
+		}
+		return raw.Decode(osf, st.Size())
+	}
+	// Archive entries can't be read at random positions.
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return raw.Decode(bytes.NewReader(b), int64(len(b)))
+}
+
+// Rotates img clockwise by deg degrees, a multiple of 90.
+func Rotate(img image.Image, deg int) image.Image {
+	// Map to the equivalent EXIF orientations.
+	switch document.NormRotation(deg) {
+	case 90:
+		return raw.Orient(img, 6)
+	case 180:
+		return raw.Orient(img, 3)
+	case 270:
+		return raw.Orient(img, 8)
+	}
+	return img
+}
+
+// Loads the image of page, applying its settings.
+func LoadPage(page document.Page) (image.Image, error) {
+	img, err := Load(page.Path)
+	if err != nil {
+		return nil, err
+	}
+	return Rotate(img, page.Rotation), nil
+}
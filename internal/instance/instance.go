@@ -0,0 +1,113 @@
+// Package instance makes sure only one pic4pdf window runs per session.
+//
+// The first process owns a name on the D-Bus session bus. Later processes
+// hand their command line arguments to it and exit.
+package instance
+
+import (
+	"net/url"
+	"path/filepath"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	busName    = "com.pic4pdf"
+	objectPath = dbus.ObjectPath("/com/pic4pdf")
+	iface      = "com.pic4pdf.Instance"
+)
+
+// The running primary instance.
+type Instance struct {
+	conn *dbus.Conn
+
+	mu      sync.Mutex
+	onOpen  func(args []string)
+	pending [][]string
+}
+
+// Exported on the bus.
+type handler struct {
+	in *Instance
+}
+
+func (h handler) Open(args []string) *dbus.Error {
+	h.in.mu.Lock()
+	fn := h.in.onOpen
+	if fn == nil {
+		h.in.pending = append(h.in.pending, args)
+	}
+	h.in.mu.Unlock()
+	if fn != nil {
+		fn(args)
+	}
+	return nil
+}
+
+// Makes relative paths absolute, as the running instance may have a
+// different working directory.
+func absArgs(args []string) []string {
+	res := make([]string, len(args))
+	for i, arg := range args {
+		res[i] = arg
+		if u, err := url.Parse(arg); err == nil && u.Scheme != "" {
+			continue
+		}
+		if p, err := filepath.Abs(arg); err == nil {
+			res[i] = p
+		}
+	}
+	return res
+}
+
+// Registers this process as the primary instance.
+//
+// If another instance is already running, args are handed to it and
+// forwarded is true; the caller should exit. If the session bus is not
+// available, err is non-nil and the caller should continue on its own.
+func Start(args []string) (in *Instance, forwarded bool, err error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, false, err
+	}
+	reply, err := conn.RequestName(busName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		conn.Close()
+		return nil, false, err
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		defer conn.Close()
+		call := conn.Object(busName, objectPath).Call(iface+".Open", 0, absArgs(args))
+		if call.Err != nil {
+			return nil, false, call.Err
+		}
+		return nil, true, nil
+	}
+	in = &Instance{conn: conn}
+	if err := conn.Export(handler{in: in}, objectPath, iface); err != nil {
+		conn.Close()
+		return nil, false, err
+	}
+	return in, false, nil
+}
+
+// Sets the function called with the arguments of every later launch.
+// Arguments received before are passed to fn immediately.
+//
+// fn is called from a separate goroutine.
+func (in *Instance) SetOnOpen(fn func(args []string)) {
+	in.mu.Lock()
+	in.onOpen = fn
+	pending := in.pending
+	in.pending = nil
+	in.mu.Unlock()
+	for _, args := range pending {
+		fn(args)
+	}
+}
+
+// Releases the bus name.
+func (in *Instance) Close() error {
+	return in.conn.Close()
+}
@@ -0,0 +1,88 @@
+// Package natsort orders strings the way humans expect, so that
+// "IMG_2.jpg" comes before "IMG_10.jpg".
+package natsort
+
+import (
+	"sort"
+	"strings"
+)
+
+func isDigit(b byte) bool {
+	return '0' <= b && b <= '9'
+}
+
+// Compares a and b in natural order, returning -1, 0 or 1.
+//
+// Runs of digits are compared by their numeric value, everything else is
+// compared case-insensitively. Strings that only differ in case or leading
+// zeros fall back to a plain byte comparison, so the order is total.
+func Compare(a, b string) int {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		ca, cb := a[i], b[j]
+		if isDigit(ca) && isDigit(cb) {
+			// Skip leading zeros.
+			si, sj := i, j
+			for si < len(a) && a[si] == '0' {
+				si++
+			}
+			for sj < len(b) && b[sj] == '0' {
+				sj++
+			}
+			ei, ej := si, sj
+			for ei < len(a) && isDigit(a[ei]) {
+				ei++
+			}
+			for ej < len(b) && isDigit(b[ej]) {
+				ej++
+			}
+			// A longer run of significant digits is the larger number.
+			if ei-si != ej-sj {
+				if ei-si < ej-sj {
+					return -1
+				}
+				return 1
+			}
+			if c := strings.Compare(a[si:ei], b[sj:ej]); c != 0 {
+				return c
+			}
+			i, j = ei, ej
+			continue
+		}
+		la, lb := lower(ca), lower(cb)
+		if la != lb {
+			if la < lb {
+				return -1
+			}
+			return 1
+		}
+		i++
+		j++
+	}
+	if len(a)-i != len(b)-j {
+		if len(a)-i < len(b)-j {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func lower(b byte) byte {
+	if 'A' <= b && b <= 'Z' {
+		return b + 'a' - 'A'
+	}
+	return b
+}
+
+// Reports whether a sorts before b in natural order.
+func Less(a, b string) bool {
+	return Compare(a, b) < 0
+}
+
+// Sorts s in natural order.
+func Strings(s []string) {
+	sort.SliceStable(s, func(i, j int) bool {
+		return Less(s[i], s[j])
+	})
+}
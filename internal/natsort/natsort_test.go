@@ -0,0 +1,56 @@
+package natsort
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"IMG_2.jpg", "IMG_10.jpg", -1},
+		{"IMG_10.jpg", "IMG_10.jpg", 0},
+		{"IMG_9.jpg", "IMG_10.jpg", -1},
+		{"IMG_10.jpg", "IMG_10a.jpg", -1},
+		// Leading zeros only decide between otherwise equal strings.
+		{"IMG_002.jpg", "IMG_10.jpg", -1},
+		{"IMG_010.jpg", "IMG_9.jpg", 1},
+		{"IMG_007.jpg", "IMG_7.jpg", -1},
+		{"0", "00", -1},
+		// Case only decides between otherwise equal strings.
+		{"a.jpg", "B.jpg", -1},
+		{"b.jpg", "A.jpg", 1},
+		{"A.jpg", "a.jpg", -1},
+		{"Scan 2", "scan 10", -1},
+		// Digit runs at the end.
+		{"page", "page1", -1},
+		{"page1", "page10", -1},
+		{"page10", "page9", 1},
+		{"page9", "page09", 1},
+		{"2", "10", -1},
+		{"", "0", -1},
+		{"99999999999999999999999", "100000000000000000000000", -1},
+		{"a10b", "a10a", 1},
+		{"a10", "a10b", -1},
+		{"1a", "a", -1},
+	}
+	for _, tt := range tests {
+		if got := Compare(tt.a, tt.b); got != tt.want {
+			t.Errorf("Compare(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+		if got := Compare(tt.b, tt.a); got != -tt.want {
+			t.Errorf("Compare(%q, %q) = %v, want %v", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
+
+func TestStrings(t *testing.T) {
+	s := []string{"IMG_10.jpg", "img_3.jpg", "IMG_2.jpg", "IMG_02.jpg", "IMG_1.jpg", "IMG_.jpg"}
+	Strings(s)
+	want := []string{"IMG_.jpg", "IMG_1.jpg", "IMG_02.jpg", "IMG_2.jpg", "img_3.jpg", "IMG_10.jpg"}
+	if !slices.Equal(s, want) {
+		t.Errorf("got %q, want %q", s, want)
+	}
+}
@@ -0,0 +1,178 @@
+// Package paste stores images pasted from the system clipboard.
+//
+// Pasted images are written to a temporary directory under the XDG cache,
+// which lives as long as the session. Call Cleanup before exiting, and
+// RemoveStale at startup for directories left by crashed sessions.
+package paste
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/adrg/xdg"
+	"golang.design/x/clipboard"
+)
+
+var ErrNoImage = errors.New("clipboard does not contain an image")
+
+var (
+	initOnce sync.Once
+	initErr  error
+
+	mu    sync.Mutex
+	dir   string
+	count int
+	// Labels of pasted images by path.
+	names = make(map[string]string)
+)
+
+// Returns the session directory, creating it if necessary.
+// Requires mu to be locked!
+func sessionDir() (string, error) {
+	if dir != "" {
+		return dir, nil
+	}
+	base := filepath.Join(xdg.CacheHome, "pic4pdf")
+	if err := os.MkdirAll(base, 0o700); err != nil {
+		return "", err
+	}
+	d, err := os.MkdirTemp(base, "paste-*")
+	if err != nil {
+		return "", err
+	}
+	// Marks the directory as owned by this process for RemoveStale.
+	pid := []byte(strconv.Itoa(os.Getpid()))
+	if err := os.WriteFile(filepath.Join(d, ownerFile), pid, 0o600); err != nil {
+		os.RemoveAll(d)
+		return "", err
+	}
+	dir = d
+	return dir, nil
+}
+
+// Name of the file with the process ID of the owner of a session directory.
+const ownerFile = "pid"
+
+// Reports whether a process with the given ID is running.
+func running(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		// FindProcess fails for processes which don't exist.
+		return true
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// Deletes the session directories of processes which are no longer
+// running, e.g. because they crashed.
+func RemoveStale() error {
+	base := filepath.Join(xdg.CacheHome, "pic4pdf")
+	ents, err := os.ReadDir(base)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var errs []error
+	for _, ent := range ents {
+		if !ent.IsDir() || !strings.HasPrefix(ent.Name(), "paste-") {
+			continue
+		}
+		d := filepath.Join(base, ent.Name())
+		data, err := os.ReadFile(filepath.Join(d, ownerFile))
+		if errors.Is(err, os.ErrNotExist) {
+			// The owner may not have written the file yet.
+			if info, err := ent.Info(); err != nil || time.Since(info.ModTime()) < time.Minute {
+				continue
+			}
+		} else if err != nil {
+			errs = append(errs, err)
+			continue
+		} else if pid, err := strconv.Atoi(string(data)); err == nil && running(pid) {
+			continue
+		}
+		if err := os.RemoveAll(d); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Saves the image currently in the clipboard and returns its path.
+func FromClipboard() (path string, err error) {
+	initOnce.Do(func() {
+		initErr = clipboard.Init()
+	})
+	if initErr != nil {
+		return "", fmt.Errorf("clipboard unavailable: %w", initErr)
+	}
+	data := clipboard.Read(clipboard.FmtImage)
+	if len(data) == 0 {
+		return "", ErrNoImage
+	}
+	if _, _, err := image.DecodeConfig(bytes.NewReader(data)); err != nil {
+		return "", fmt.Errorf("invalid image in clipboard: %w", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	d, err := sessionDir()
+	if err != nil {
+		return "", err
+	}
+	count++
+	path = filepath.Join(d, fmt.Sprintf("pasted-%v.png", count))
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return "", err
+	}
+	names[path] = fmt.Sprintf("Pasted image %v", count)
+	return path, nil
+}
+
+// Returns the label of a pasted image, e.g. "Pasted image 3".
+// ok is false if path is not a pasted image.
+func Name(path string) (name string, ok bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	name, ok = names[path]
+	return
+}
+
+// Deletes the pasted image at path. Does nothing if path is not a pasted image.
+func Remove(path string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := names[path]; !ok {
+		return nil
+	}
+	delete(names, path)
+	return os.Remove(path)
+}
+
+// Deletes all pasted images of this session.
+func Cleanup() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if dir == "" {
+		return nil
+	}
+	err := os.RemoveAll(dir)
+	dir = ""
+	names = make(map[string]string)
+	return err
+}
@@ -0,0 +1,63 @@
+package paste
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/adrg/xdg"
+)
+
+func TestRemoveStale(t *testing.T) {
+	cache := xdg.CacheHome
+	xdg.CacheHome = t.TempDir()
+	defer func() { xdg.CacheHome = cache }()
+	base := filepath.Join(xdg.CacheHome, "pic4pdf")
+
+	// A process which has exited.
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	dead := cmd.Process.Pid
+	old := time.Now().Add(-time.Hour)
+	dirs := []struct {
+		name string
+		// Empty for none.
+		pid     string
+		modTime time.Time
+		keep    bool
+	}{
+		{"paste-own", strconv.Itoa(os.Getpid()), old, true},
+		{"paste-dead", strconv.Itoa(dead), old, false},
+		{"paste-new", "", time.Now(), true},
+		{"paste-orphan", "", old, false},
+		{"other", "", old, true},
+	}
+	for _, d := range dirs {
+		p := filepath.Join(base, d.name)
+		if err := os.MkdirAll(p, 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if d.pid != "" {
+			if err := os.WriteFile(filepath.Join(p, ownerFile), []byte(d.pid), 0o600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := os.Chtimes(p, d.modTime, d.modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := RemoveStale(); err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range dirs {
+		_, err := os.Stat(filepath.Join(base, d.name))
+		if kept := err == nil; kept != d.keep {
+			t.Errorf("%v: kept = %v, want %v", d.name, kept, d.keep)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package pdf
+
+import "image"
+
+// A code of the CCITT T.4 and T.6 tables, as value and length in bits.
+type faxCode struct {
+	bits uint16
+	n    uint8
+}
+
+// Terminating codes for white runs of 0 to 63 pixels.
+var whiteTerm = [64]faxCode{
+	{0x35, 8}, {0x07, 6}, {0x07, 4}, {0x08, 4}, {0x0b, 4}, {0x0c, 4}, {0x0e, 4}, {0x0f, 4},
+	{0x13, 5}, {0x14, 5}, {0x07, 5}, {0x08, 5}, {0x08, 6}, {0x03, 6}, {0x34, 6}, {0x35, 6},
+	{0x2a, 6}, {0x2b, 6}, {0x27, 7}, {0x0c, 7}, {0x08, 7}, {0x17, 7}, {0x03, 7}, {0x04, 7},
+	{0x28, 7}, {0x2b, 7}, {0x13, 7}, {0x24, 7}, {0x18, 7}, {0x02, 8}, {0x03, 8}, {0x1a, 8},
+	{0x1b, 8}, {0x12, 8}, {0x13, 8}, {0x14, 8}, {0x15, 8}, {0x16, 8}, {0x17, 8}, {0x28, 8},
+	{0x29, 8}, {0x2a, 8}, {0x2b, 8}, {0x2c, 8}, {0x2d, 8}, {0x04, 8}, {0x05, 8}, {0x0a, 8},
+	{0x0b, 8}, {0x52, 8}, {0x53, 8}, {0x54, 8}, {0x55, 8}, {0x24, 8}, {0x25, 8}, {0x58, 8},
+	{0x59, 8}, {0x5a, 8}, {0x5b, 8}, {0x4a, 8}, {0x4b, 8}, {0x32, 8}, {0x33, 8}, {0x34, 8},
+}
+
+// Make-up codes for white runs of 64 to 1728 pixels, in steps of 64.
+var whiteMakeUp = [27]faxCode{
+	{0x1b, 5}, {0x12, 5}, {0x17, 6}, {0x37, 7}, {0x36, 8}, {0x37, 8}, {0x64, 8}, {0x65, 8},
+	{0x68, 8}, {0x67, 8}, {0xcc, 9}, {0xcd, 9}, {0xd2, 9}, {0xd3, 9}, {0xd4, 9}, {0xd5, 9},
+	{0xd6, 9}, {0xd7, 9}, {0xd8, 9}, {0xd9, 9}, {0xda, 9}, {0xdb, 9}, {0x98, 9}, {0x99, 9},
+	{0x9a, 9}, {0x18, 6}, {0x9b, 9},
+}
+
+// Terminating codes for black runs of 0 to 63 pixels.
+var blackTerm = [64]faxCode{
+	{0x37, 10}, {0x02, 3}, {0x03, 2}, {0x02, 2}, {0x03, 3}, {0x03, 4}, {0x02, 4}, {0x03, 5},
+	{0x05, 6}, {0x04, 6}, {0x04, 7}, {0x05, 7}, {0x07, 7}, {0x04, 8}, {0x07, 8}, {0x18, 9},
+	{0x17, 10}, {0x18, 10}, {0x08, 10}, {0x67, 11}, {0x68, 11}, {0x6c, 11}, {0x37, 11}, {0x28, 11},
+	{0x17, 11}, {0x18, 11}, {0xca, 12}, {0xcb, 12}, {0xcc, 12}, {0xcd, 12}, {0x68, 12}, {0x69, 12},
+	{0x6a, 12}, {0x6b, 12}, {0xd2, 12}, {0xd3, 12}, {0xd4, 12}, {0xd5, 12}, {0xd6, 12}, {0xd7, 12},
+	{0x6c, 12}, {0x6d, 12}, {0xda, 12}, {0xdb, 12}, {0x54, 12}, {0x55, 12}, {0x56, 12}, {0x57, 12},
+	{0x64, 12}, {0x65, 12}, {0x52, 12}, {0x53, 12}, {0x24, 12}, {0x37, 12}, {0x38, 12}, {0x27, 12},
+	{0x28, 12}, {0x58, 12}, {0x59, 12}, {0x2b, 12}, {0x2c, 12}, {0x5a, 12}, {0x66, 12}, {0x67, 12},
+}
+
+// Make-up codes for black runs of 64 to 1728 pixels, in steps of 64.
+var blackMakeUp = [27]faxCode{
+	{0x0f, 10}, {0xc8, 12}, {0xc9, 12}, {0x5b, 12}, {0x33, 12}, {0x34, 12}, {0x35, 12}, {0x6c, 13},
+	{0x6d, 13}, {0x4a, 13}, {0x4b, 13}, {0x4c, 13}, {0x4d, 13}, {0x72, 13}, {0x73, 13}, {0x74, 13},
+	{0x75, 13}, {0x76, 13}, {0x77, 13}, {0x52, 13}, {0x53, 13}, {0x54, 13}, {0x55, 13}, {0x5a, 13},
+	{0x5b, 13}, {0x64, 13}, {0x65, 13},
+}
+
+// Make-up codes for runs of either colour of 1792 to 2560 pixels.
+var extMakeUp = [13]faxCode{
+	{0x08, 11}, {0x0c, 11}, {0x0d, 11}, {0x12, 12}, {0x13, 12}, {0x14, 12}, {0x15, 12},
+	{0x16, 12}, {0x17, 12}, {0x1c, 12}, {0x1d, 12}, {0x1e, 12}, {0x1f, 12},
+}
+
+var (
+	passCode  = faxCode{0x1, 4}
+	horizCode = faxCode{0x1, 3}
+	eolCode   = faxCode{0x1, 12}
+	// Vertical mode codes for a1 - b1 = -3 to 3.
+	vertCodes = [7]faxCode{
+		{0x02, 7}, {0x02, 6}, {0x02, 3}, {0x1, 1}, {0x03, 3}, {0x03, 6}, {0x03, 7},
+	}
+)
+
+type bitWriter struct {
+	buf []byte
+	acc uint32
+	n   uint
+}
+
+func (b *bitWriter) write(c faxCode) {
+	b.acc = b.acc<<c.n | uint32(c.bits)
+	b.n += uint(c.n)
+	for b.n >= 8 {
+		b.n -= 8
+		b.buf = append(b.buf, byte(b.acc>>b.n))
+	}
+}
+
+func (b *bitWriter) bytes() []byte {
+	if b.n > 0 {
+		b.buf = append(b.buf, byte(b.acc<<(8-b.n)))
+		b.n = 0
+	}
+	return b.buf
+}
+
+// Writes the codes of a run of n pixels.
+func (b *bitWriter) run(n int, black bool) {
+	term, makeUp := &whiteTerm, &whiteMakeUp
+	if black {
+		term, makeUp = &blackTerm, &blackMakeUp
+	}
+	for n >= 2560+64 {
+		b.write(extMakeUp[len(extMakeUp)-1])
+		n -= 2560
+	}
+	if n >= 64 {
+		m := n / 64
+		if m <= len(makeUp) {
+			b.write(makeUp[m-1])
+		} else {
+			b.write(extMakeUp[m-len(makeUp)-1])
+		}
+		n -= m * 64
+	}
+	b.write(term[n])
+}
+
+// Returns the first position from start on where line differs from black,
+// or len(line).
+func nextChange(line []bool, start int, black bool) int {
+	for start < len(line) && line[start] == black {
+		start++
+	}
+	return start
+}
+
+// Returns an image XObject of img in black and white compressed with CCITT
+// Group 4, as fax machines do. Pixels darker than 50% are black.
+func (d *Document) CCITTImage(img *image.Gray) *Stream {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	var bw bitWriter
+	ref := make([]bool, w)
+	line := make([]bool, w)
+	for y := 0; y < h; y++ {
+		row := img.Pix[y*img.Stride : y*img.Stride+w]
+		for x, v := range row {
+			line[x] = v < 0x80
+		}
+		// a0 starts on an imaginary white pixel before the line.
+		a0, black := 0, false
+		a1 := nextChange(line, 0, false)
+		b1 := nextChange(ref, 0, false)
+		for {
+			b2 := nextChange(ref, b1, b1 < w && ref[b1])
+			if b2 < a1 {
+				bw.write(passCode)
+				a0 = b2
+			} else if d := a1 - b1; d >= -3 && d <= 3 {
+				bw.write(vertCodes[d+3])
+				a0 = a1
+				black = !black
+			} else {
+				a2 := nextChange(line, a1, !black)
+				bw.write(horizCode)
+				bw.run(a1-a0, black)
+				bw.run(a2-a1, !black)
+				a0 = a2
+			}
+			if a0 >= w {
+				break
+			}
+			a1 = nextChange(line, a0, black)
+			// b1 is the next change to the colour opposite of a0's on the
+			// reference line.
+			b1 = nextChange(ref, nextChange(ref, a0, !black), black)
+		}
+		ref, line = line, ref
+	}
+	// End of facsimile block.
+	bw.write(eolCode)
+	bw.write(eolCode)
+	return &Stream{
+		Dict: Dict{
+			"Type":             Name("XObject"),
+			"Subtype":          Name("Image"),
+			"Width":            w,
+			"Height":           h,
+			"BitsPerComponent": 1,
+			"ColorSpace":       Name("DeviceGray"),
+			"Filter":           Name("CCITTFaxDecode"),
+			"DecodeParms": Dict{
+				"K":       -1,
+				"Columns": w,
+				"Rows":    h,
+			},
+		},
+		Data: bw.bytes(),
+	}
+}
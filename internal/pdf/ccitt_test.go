@@ -0,0 +1,107 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"math/rand"
+	"testing"
+
+	"golang.org/x/image/ccitt"
+)
+
+// Returns a w by h image with rows made by fill, which sets black pixels.
+func bwImage(w, h int, fill func(row []byte, y int)) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	for y := 0; y < h; y++ {
+		fill(img.Pix[y*img.Stride:y*img.Stride+w], y)
+	}
+	return img
+}
+
+func TestCCITTImage(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	type test struct {
+		name string
+		img  *image.Gray
+	}
+	var tests []test
+	// Around the limits of the terminating, make-up and extended make-up
+	// codes, and runs needing several extended make-up codes.
+	for _, w := range []int{1, 2, 3, 63, 64, 65, 127, 128, 1727, 1728, 1729, 1791, 1792, 2559, 2560, 2561, 2623, 2624, 2625, 5120, 5185, 7000} {
+		tests = append(tests,
+			test{fmt.Sprint("white/", w), bwImage(w, 3, func(row []byte, y int) {})},
+			test{fmt.Sprint("black/", w), bwImage(w, 3, func(row []byte, y int) {
+				clear(row)
+			})},
+			// White and black rows, so each is coded relative to the other.
+			test{fmt.Sprint("alternating/", w), bwImage(w, 6, func(row []byte, y int) {
+				if y%2 == 1 {
+					clear(row)
+				}
+			})},
+			test{fmt.Sprint("edges/", w), bwImage(w, 4, func(row []byte, y int) {
+				row[0] = 0
+				row[len(row)-1] = byte(y%2) * 0xff
+			})},
+			test{fmt.Sprint("noise/", w), bwImage(w, 8, func(row []byte, y int) {
+				for x := range row {
+					row[x] = byte(rnd.Intn(2)) * 0xff
+				}
+			})},
+			// Long runs of random length, which exercise every mode.
+			test{fmt.Sprint("runs/", w), bwImage(w, 16, func(row []byte, y int) {
+				v := byte(rnd.Intn(2)) * 0xff
+				for x := 0; x < len(row); {
+					n := 1 + rnd.Intn(rnd.Intn(3000)+1)
+					for ; n > 0 && x < len(row); n-- {
+						row[x] = v
+						x++
+					}
+					v = ^v
+				}
+			})},
+		)
+	}
+	// Similar to a scanned page, with lines changing little between rows.
+	tests = append(tests, test{"page", bwImage(2480, 200, func(row []byte, y int) {
+		for x := 200; x < len(row)-200; x += 40 {
+			if (y/20)%2 == 0 && (x/40+y/7)%3 != 0 {
+				clear(row[x+y%5 : x+30])
+			}
+		}
+	})})
+	// Gray values, which are black below 50%.
+	tests = append(tests, test{"gray", bwImage(256, 4, func(row []byte, y int) {
+		for x := range row {
+			row[x] = byte(x)
+		}
+	})})
+
+	var d Document
+	for _, tt := range tests {
+		b := tt.img.Bounds()
+		s := d.CCITTImage(tt.img)
+		got := image.NewGray(b)
+		if err := ccitt.DecodeIntoGray(got, bytes.NewReader(s.Data), ccitt.MSB, ccitt.Group4, nil); err != nil {
+			t.Errorf("%v: decode: %v", tt.name, err)
+			continue
+		}
+	pixels:
+		for y := 0; y < b.Dy(); y++ {
+			for x := 0; x < b.Dx(); x++ {
+				want := byte(0xff)
+				if tt.img.GrayAt(x, y).Y < 0x80 {
+					want = 0
+				}
+				if v := got.GrayAt(x, y).Y; v != want {
+					t.Errorf("%v: pixel (%v, %v) is %#x, want %#x", tt.name, x, y, v, want)
+					break pixels
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package pdf
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"hash"
+)
+
+// Permissions granted when a document is opened with the user password.
+type Permissions int
+
+const (
+	PermitPrint Permissions = 1 << iota
+	// Copying text and images. Extraction for accessibility is always
+	// allowed.
+	PermitCopy
+	// Changing pages, annotations and form fields.
+	PermitModify
+
+	PermitAll = PermitPrint | PermitCopy | PermitModify
+)
+
+// Settings of the standard security handler. Documents are encrypted with
+// AES-256 (revision 6, as in PDF 2.0).
+type Encryption struct {
+	// Needed to open the document. May be empty, so that only the
+	// permissions apply.
+	UserPassword string
+	// Grants all permissions. If empty, a random one is used, so the
+	// permissions can't be lifted.
+	OwnerPassword string
+	Permissions   Permissions
+}
+
+// Value of the P entry: bits 3 (print), 4 (modify), 5 (copy), 6
+// (annotations), 9 (fill forms), 10 (accessibility), 11 (assemble) and 12
+// (high quality print), with the reserved bits set.
+func (p Permissions) flags() int32 {
+	flags := ^int32(0xfff) | 0xc0 | 1<<9
+	if p&PermitPrint != 0 {
+		flags |= 1<<2 | 1<<11
+	}
+	if p&PermitModify != 0 {
+		flags |= 1<<3 | 1<<5 | 1<<8 | 1<<10
+	}
+	if p&PermitCopy != 0 {
+		flags |= 1 << 4
+	}
+	return flags
+}
+
+// Encrypts strings and streams with the file key.
+type encrypter struct {
+	block cipher.Block
+}
+
+// Returns the Encrypt dictionary and an encrypter using a new random file
+// key.
+func (e *Encryption) setup() (Dict, *encrypter, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, nil, err
+	}
+	owner := e.OwnerPassword
+	if owner == "" {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		owner = string(b)
+	}
+	u, ue, err := passwordEntries(e.UserPassword, key, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	o, oe, err := passwordEntries(owner, key, u)
+	if err != nil {
+		return nil, nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	flags := e.Permissions.flags()
+	perms := make([]byte, 16)
+	binary.LittleEndian.PutUint32(perms, uint32(flags))
+	copy(perms[4:], "\xff\xff\xff\xffTadb")
+	if _, err := rand.Read(perms[12:]); err != nil {
+		return nil, nil, err
+	}
+	block.Encrypt(perms, perms)
+	dict := Dict{
+		"Filter": Name("Standard"),
+		"V":      5,
+		"R":      6,
+		"Length": 256,
+		"CF": Dict{"StdCF": Dict{
+			"CFM":       Name("AESV3"),
+			"AuthEvent": Name("DocOpen"),
+			"Length":    32,
+		}},
+		"StmF":  Name("StdCF"),
+		"StrF":  Name("StdCF"),
+		"O":     hexString(o),
+		"U":     hexString(u),
+		"OE":    hexString(oe),
+		"UE":    hexString(ue),
+		"P":     int(flags),
+		"Perms": hexString(perms),
+	}
+	return dict, &encrypter{block: block}, nil
+}
+
+// Returns the O and OE (with u set to the U entry) or U and UE entries for
+// password.
+func passwordEntries(password string, key, u []byte) (entry, keyEntry []byte, err error) {
+	pw := []byte(password)
+	if len(pw) > 127 {
+		pw = pw[:127]
+	}
+	// Validation salt and key salt.
+	salts := make([]byte, 16)
+	if _, err := rand.Read(salts); err != nil {
+		return nil, nil, err
+	}
+	entry = append(hash2B(pw, salts[:8], u), salts...)
+	block, err := aes.NewCipher(hash2B(pw, salts[8:], u))
+	if err != nil {
+		return nil, nil, err
+	}
+	keyEntry = make([]byte, len(key))
+	cipher.NewCBCEncrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(keyEntry, key)
+	return entry, keyEntry, nil
+}
+
+// Computes a hash of a password as in algorithm 2.B of ISO 32000-2.
+func hash2B(pw, salt, u []byte) []byte {
+	h := sha256.New()
+	h.Write(pw)
+	h.Write(salt)
+	h.Write(u)
+	k := h.Sum(nil)
+	for round := 0; ; round++ {
+		k1 := bytes.Repeat(append(append(append([]byte{}, pw...), k...), u...), 64)
+		block, _ := aes.NewCipher(k[:16])
+		e := make([]byte, len(k1))
+		cipher.NewCBCEncrypter(block, k[16:32]).CryptBlocks(e, k1)
+		sum := 0
+		for _, c := range e[:16] {
+			sum += int(c)
+		}
+		var h hash.Hash
+		switch sum % 3 {
+		case 0:
+			h = sha256.New()
+		case 1:
+			h = sha512.New384()
+		case 2:
+			h = sha512.New()
+		}
+		h.Write(e)
+		k = h.Sum(nil)
+		if round >= 63 && int(e[len(e)-1]) <= round-31 {
+			break
+		}
+	}
+	return k[:32]
+}
+
+// Encrypts data with a random initialization vector, which precedes the
+// result.
+func (e *encrypter) encrypt(data []byte) ([]byte, error) {
+	n := aes.BlockSize - len(data)%aes.BlockSize
+	res := make([]byte, aes.BlockSize+len(data)+n)
+	iv := res[:aes.BlockSize]
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+	copy(res[aes.BlockSize:], data)
+	for i := len(res) - n; i < len(res); i++ {
+		res[i] = byte(n)
+	}
+	cipher.NewCBCEncrypter(e.block, iv).CryptBlocks(res[aes.BlockSize:], res[aes.BlockSize:])
+	return res, nil
+}
+
+// Returns v with strings encrypted.
+func (e *encrypter) value(v any) (any, error) {
+	switch v := v.(type) {
+	case String:
+		b, err := e.encrypt(textString(string(v)))
+		return hexString(b), err
+	case hexString:
+		b, err := e.encrypt([]byte(v))
+		return hexString(b), err
+	case Array:
+		res := make(Array, len(v))
+		for i, x := range v {
+			var err error
+			if res[i], err = e.value(x); err != nil {
+				return nil, err
+			}
+		}
+		return res, nil
+	case Dict:
+		res := make(Dict, len(v))
+		for k, x := range v {
+			var err error
+			if res[k], err = e.value(x); err != nil {
+				return nil, err
+			}
+		}
+		return res, nil
+	case *Stream:
+		dict, err := e.value(v.Dict)
+		if err != nil {
+			return nil, err
+		}
+		data, err := e.encrypt(v.Data)
+		if err != nil {
+			return nil, err
+		}
+		return &Stream{Dict: dict.(Dict), Data: data}, nil
+	}
+	return v, nil
+}
@@ -0,0 +1,132 @@
+package pdf
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+// Returns the hexadecimal string of key in the Encrypt dictionary of data.
+func encryptEntry(t *testing.T, data []byte, key string) []byte {
+	t.Helper()
+	m := regexp.MustCompile(`/` + key + `\s*<([0-9A-Fa-f]*)>`).FindSubmatch(data)
+	if m == nil {
+		t.Fatalf("no %v entry", key)
+	}
+	b, err := hex.DecodeString(string(m[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// Returns the file key if password is the user or owner password, as in
+// algorithm 2.A of ISO 32000-2.
+func fileKey(t *testing.T, data []byte, password string, owner bool) []byte {
+	t.Helper()
+	u := encryptEntry(t, data, "U")
+	entry, keyEntry, extra := u, encryptEntry(t, data, "UE"), []byte(nil)
+	if owner {
+		entry, keyEntry, extra = encryptEntry(t, data, "O"), encryptEntry(t, data, "OE"), u
+	}
+	if len(entry) != 48 || len(keyEntry) != 32 || len(u) != 48 {
+		t.Fatalf("entries are %v and %v bytes long, want 48 and 32", len(entry), len(keyEntry))
+	}
+	if !bytes.Equal(hash2B([]byte(password), entry[32:40], extra), entry[:32]) {
+		return nil
+	}
+	block, err := aes.NewCipher(hash2B([]byte(password), entry[40:48], extra))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := make([]byte, 32)
+	cipher.NewCBCDecrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(key, keyEntry)
+	return key
+}
+
+// Returns the plain text of data, a string or stream encrypted with key.
+func decrypt(t *testing.T, key, data []byte) []byte {
+	t.Helper()
+	if len(data) < 2*aes.BlockSize || len(data)%aes.BlockSize != 0 {
+		t.Fatalf("encrypted data is %v bytes long", len(data))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := make([]byte, len(data)-aes.BlockSize)
+	cipher.NewCBCDecrypter(block, data[:aes.BlockSize]).CryptBlocks(res, data[aes.BlockSize:])
+	n := int(res[len(res)-1])
+	if n < 1 || n > aes.BlockSize {
+		t.Fatalf("invalid padding %v", n)
+	}
+	return res[:len(res)-n]
+}
+
+func TestEncryption(t *testing.T) {
+	const (
+		content = "BT /F1 12 Tf (secret) Tj ET"
+		title   = "Secret Title"
+	)
+	d := New()
+	d.Encryption = &Encryption{UserPassword: "user", OwnerPassword: "owner", Permissions: PermitPrint}
+	d.Info["Title"] = String(title)
+	ref := d.Add(&Stream{Dict: Dict{}, Data: []byte(content)})
+	var b bytes.Buffer
+	if _, err := d.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	data := b.Bytes()
+	if bytes.Contains(data, []byte("secret")) || bytes.Contains(data, []byte(title)) {
+		t.Fatal("plain text in encrypted file")
+	}
+
+	key := fileKey(t, data, "user", false)
+	if key == nil {
+		t.Fatal("user password rejected")
+	}
+	if ownerKey := fileKey(t, data, "owner", true); !bytes.Equal(ownerKey, key) {
+		t.Fatalf("file key from the owner password is %x, want %x", ownerKey, key)
+	}
+	if fileKey(t, data, "wrong", false) != nil || fileKey(t, data, "wrong", true) != nil {
+		t.Fatal("wrong password accepted")
+	}
+	if fileKey(t, data, "user", true) != nil {
+		t.Fatal("user password accepted as owner password")
+	}
+
+	// Perms holds the permissions encrypted with the file key.
+	perms := encryptEntry(t, data, "Perms")
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block.Decrypt(perms, perms)
+	m := regexp.MustCompile(`/P\s+(-?\d+)`).FindSubmatch(data)
+	if m == nil {
+		t.Fatal("no P entry")
+	}
+	p, _ := strconv.Atoi(string(m[1]))
+	if got := int32(binary.LittleEndian.Uint32(perms)); got != int32(p) || string(perms[8:12]) != "Tadb" {
+		t.Errorf("Perms decrypts to %x, want P %v and Tadb", perms, p)
+	}
+	if p&(1<<2) == 0 || p&(1<<4) != 0 || p&(1<<3) != 0 {
+		t.Errorf("P is %b, want printing allowed only", p)
+	}
+
+	obj := data[bytes.Index(data, []byte(fmt.Sprintf("\n%v 0 obj\n", ref))):]
+	start := bytes.Index(obj, []byte("stream\n")) + len("stream\n")
+	end := bytes.Index(obj, []byte("\nendstream"))
+	if got := decrypt(t, key, obj[start:end]); string(got) != content {
+		t.Errorf("stream decrypts to %q, want %q", got, content)
+	}
+	if got := decrypt(t, key, encryptEntry(t, data, "Title")); string(got) != title {
+		t.Errorf("title decrypts to %q, want %q", got, title)
+	}
+}
@@ -0,0 +1,173 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf16"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// A TrueType font embedded as a whole, addressing glyphs by their index
+// (Identity-H encoding).
+type Font struct {
+	Ref Ref
+
+	ttf  []byte
+	sfnt *sfnt.Font
+	buf  sfnt.Buffer
+	// Runes of the glyphs used, for the ToUnicode map.
+	used map[sfnt.GlyphIndex]rune
+	// Runes shown as .notdef.
+	missing map[rune]bool
+}
+
+// Adds a TrueType font. It is written with the document.
+func (d *Document) AddFont(ttf []byte) (*Font, error) {
+	f, err := sfnt.Parse(ttf)
+	if err != nil {
+		return nil, err
+	}
+	fnt := &Font{
+		Ref:     d.Reserve(),
+		ttf:     ttf,
+		sfnt:    f,
+		used:    make(map[sfnt.GlyphIndex]rune),
+		missing: make(map[rune]bool),
+	}
+	d.fonts = append(d.fonts, fnt)
+	return fnt, nil
+}
+
+// Returns s as a hexadecimal string of glyph indexes for showing it with
+// the Tj operator. Runes missing in the font show as .notdef.
+func (f *Font) Encode(s string) string {
+	var b strings.Builder
+	b.WriteByte('<')
+	for _, r := range s {
+		gi, err := f.sfnt.GlyphIndex(&f.buf, r)
+		if err != nil {
+			gi = 0
+		}
+		if gi == 0 {
+			f.missing[r] = true
+		}
+		if _, ok := f.used[gi]; !ok && gi != 0 {
+			f.used[gi] = r
+		}
+		fmt.Fprintf(&b, "%04X", uint16(gi))
+	}
+	b.WriteByte('>')
+	return b.String()
+}
+
+// Converts font units to glyph space units (1/1000 em).
+func (f *Font) scale(v fixed.Int26_6) int {
+	return int(int64(v) * 1000 / 64 / int64(f.sfnt.UnitsPerEm()))
+}
+
+// Writes the font objects.
+func (f *Font) write(d *Document) error {
+	ppem := fixed.I(int(f.sfnt.UnitsPerEm()))
+	name, err := f.sfnt.Name(&f.buf, sfnt.NameIDPostScript)
+	if err != nil || name == "" {
+		name = "Font"
+	}
+	name = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' || strings.ContainsRune("()<>[]{}/%#", r) {
+			return -1
+		}
+		return r
+	}, name)
+	m, err := f.sfnt.Metrics(&f.buf, ppem, font.HintingNone)
+	if err != nil {
+		return err
+	}
+	bounds, err := f.sfnt.Bounds(&f.buf, ppem, font.HintingNone)
+	if err != nil {
+		return err
+	}
+	file := Flate(Dict{"Length1": len(f.ttf)}, f.ttf)
+	descriptor := d.Add(Dict{
+		"Type":     Name("FontDescriptor"),
+		"FontName": Name(name),
+		// Nonsymbolic.
+		"Flags": 32,
+		// Bounds have the Y axis pointing down.
+		"FontBBox": Array{
+			f.scale(bounds.Min.X), f.scale(-bounds.Max.Y),
+			f.scale(bounds.Max.X), f.scale(-bounds.Min.Y),
+		},
+		"ItalicAngle": 0,
+		"Ascent":      f.scale(m.Ascent),
+		"Descent":     -f.scale(m.Descent),
+		"CapHeight":   f.scale(m.CapHeight),
+		"StemV":       80,
+		"FontFile2":   d.Add(file),
+	})
+
+	glyphs := make([]sfnt.GlyphIndex, 0, len(f.used))
+	for gi := range f.used {
+		glyphs = append(glyphs, gi)
+	}
+	sort.Slice(glyphs, func(i, j int) bool { return glyphs[i] < glyphs[j] })
+	widths := Array{}
+	for _, gi := range glyphs {
+		adv, err := f.sfnt.GlyphAdvance(&f.buf, gi, ppem, font.HintingNone)
+		if err != nil {
+			return err
+		}
+		widths = append(widths, int(gi), Array{f.scale(adv)})
+	}
+	cidFont := d.Add(Dict{
+		"Type":     Name("Font"),
+		"Subtype":  Name("CIDFontType2"),
+		"BaseFont": Name(name),
+		"CIDSystemInfo": Dict{
+			"Registry":   String("Adobe"),
+			"Ordering":   String("Identity"),
+			"Supplement": 0,
+		},
+		"FontDescriptor": descriptor,
+		"W":              widths,
+		"CIDToGIDMap":    Name("Identity"),
+	})
+	d.Set(f.Ref, Dict{
+		"Type":            Name("Font"),
+		"Subtype":         Name("Type0"),
+		"BaseFont":        Name(name),
+		"Encoding":        Name("Identity-H"),
+		"DescendantFonts": Array{cidFont},
+		"ToUnicode":       d.Add(Flate(nil, f.toUnicode(glyphs))),
+	})
+	return nil
+}
+
+// Returns a CMap mapping glyphs to the text they show.
+func (f *Font) toUnicode(glyphs []sfnt.GlyphIndex) []byte {
+	var b bytes.Buffer
+	b.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n" +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n" +
+		"/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n" +
+		"1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	// At most 100 entries are allowed per block.
+	for len(glyphs) > 0 {
+		n := min(len(glyphs), 100)
+		fmt.Fprintf(&b, "%v beginbfchar\n", n)
+		for _, gi := range glyphs[:n] {
+			fmt.Fprintf(&b, "<%04X> <", uint16(gi))
+			for _, c := range utf16.Encode([]rune{f.used[gi]}) {
+				fmt.Fprintf(&b, "%04X", c)
+			}
+			b.WriteString(">\n")
+		}
+		b.WriteString("endbfchar\n")
+		glyphs = glyphs[n:]
+	}
+	b.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+	return b.Bytes()
+}
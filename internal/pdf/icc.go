@@ -0,0 +1,88 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+)
+
+// Returns an ICC (version 2.1) display profile for sRGB, as needed for the
+// output intent of PDF/A files. It is built from the primaries, D50 white
+// point and tone curve of IEC 61966-2-1.
+func SRGBProfile() []byte {
+	be := binary.BigEndian
+	xyz := func(x, y, z float64) []byte {
+		b := []byte("XYZ \x00\x00\x00\x00")
+		for _, v := range []float64{x, y, z} {
+			// s15Fixed16Number.
+			b = be.AppendUint32(b, uint32(int32(math.Round(v*65536))))
+		}
+		return b
+	}
+	const desc = "sRGB IEC61966-2.1"
+	descTag := []byte("desc\x00\x00\x00\x00")
+	descTag = be.AppendUint32(descTag, uint32(len(desc)+1))
+	descTag = append(descTag, desc+"\x00"...)
+	// No Unicode and ScriptCode descriptions.
+	descTag = append(descTag, make([]byte, 4+4+2+1+67)...)
+	curve := []byte("curv\x00\x00\x00\x00")
+	const n = 1024
+	curve = be.AppendUint32(curve, n)
+	for i := 0; i < n; i++ {
+		v := float64(i) / (n - 1)
+		if v <= 0.04045 {
+			v /= 12.92
+		} else {
+			v = math.Pow((v+0.055)/1.055, 2.4)
+		}
+		curve = be.AppendUint16(curve, uint16(math.Round(v*0xffff)))
+	}
+	tags := []struct {
+		sig  string
+		data []byte
+	}{
+		{"desc", descTag},
+		{"cprt", []byte("text\x00\x00\x00\x00No copyright, use freely\x00")},
+		{"wtpt", xyz(0.9642, 1, 0.8249)},
+		// Primaries adapted to D50 with the Bradford transform.
+		{"rXYZ", xyz(0.4361, 0.2225, 0.0139)},
+		{"gXYZ", xyz(0.3851, 0.7169, 0.0971)},
+		{"bXYZ", xyz(0.1431, 0.0606, 0.7141)},
+		{"rTRC", curve},
+		{"gTRC", curve},
+		{"bTRC", curve},
+	}
+
+	var table, data bytes.Buffer
+	start := 128 + 4 + 12*len(tags)
+	table.Write(be.AppendUint32(nil, uint32(len(tags))))
+	offsets := map[*byte]int{}
+	for _, t := range tags {
+		off, ok := offsets[&t.data[0]]
+		if !ok {
+			// Tag data is aligned to 4 bytes.
+			for data.Len()%4 != 0 {
+				data.WriteByte(0)
+			}
+			off = start + data.Len()
+			offsets[&t.data[0]] = off
+			data.Write(t.data)
+		}
+		table.WriteString(t.sig)
+		table.Write(be.AppendUint32(nil, uint32(off)))
+		table.Write(be.AppendUint32(nil, uint32(len(t.data))))
+	}
+
+	header := make([]byte, 128)
+	be.PutUint32(header[0:], uint32(start+data.Len()))
+	be.PutUint32(header[8:], 0x02100000)
+	copy(header[12:], "mntrRGB XYZ ")
+	// Date of creation: 2000-01-01.
+	be.PutUint16(header[24:], 2000)
+	be.PutUint16(header[26:], 1)
+	be.PutUint16(header[28:], 1)
+	copy(header[36:], "acsp")
+	// Illuminant of the profile connection space (D50).
+	copy(header[68:], xyz(0.9642, 1, 0.8249)[8:])
+	return append(append(header, table.Bytes()...), data.Bytes()...)
+}
@@ -0,0 +1,301 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+)
+
+var ErrUnsupportedJPEG = errors.New("pdf: JPEG can't be embedded as is")
+
+// What is needed to embed JPEG data.
+type JPEGInfo struct {
+	Width, Height int
+	// 1 (gray), 3 (RGB) or 4 (CMYK).
+	Components int
+	// Whether there is an Adobe APP14 marker. Adobe applications write
+	// CMYK JPEGs inverted.
+	Adobe bool
+}
+
+// Reads the markers of JPEG data up to the first scan. Returns
+// ErrUnsupportedJPEG for JPEGs that PDF readers can't decode, such as
+// 12-bit, lossless or arithmetic coded ones.
+func ParseJPEG(data []byte) (JPEGInfo, error) {
+	var info JPEGInfo
+	if len(data) < 4 || data[0] != 0xff || data[1] != 0xd8 {
+		return info, errors.New("pdf: not a JPEG")
+	}
+	sof := false
+	for i := 2; ; {
+		// Skip to the next marker, including fill bytes.
+		for i < len(data) && data[i] != 0xff {
+			i++
+		}
+		for i < len(data) && data[i] == 0xff {
+			i++
+		}
+		if i >= len(data) {
+			return info, errors.New("pdf: truncated JPEG")
+		}
+		marker := data[i]
+		i++
+		if marker == 0x01 || (marker >= 0xd0 && marker <= 0xd7) {
+			// No segment.
+			continue
+		}
+		if marker == 0xd9 || marker == 0xda {
+			// End of image or start of scan.
+			break
+		}
+		if i+2 > len(data) {
+			return info, errors.New("pdf: truncated JPEG")
+		}
+		n := int(data[i])<<8 | int(data[i+1])
+		if n < 2 || i+n > len(data) {
+			return info, errors.New("pdf: truncated JPEG")
+		}
+		seg := data[i+2 : i+n]
+		i += n
+		switch {
+		case marker == 0xee:
+			info.Adobe = len(seg) >= 5 && string(seg[:5]) == "Adobe"
+		case marker >= 0xc0 && marker <= 0xcf && marker != 0xc4 && marker != 0xc8 && marker != 0xcc:
+			if marker > 0xc2 {
+				// Lossless, hierarchical or arithmetic coding.
+				return info, ErrUnsupportedJPEG
+			}
+			if len(seg) < 6 {
+				return info, errors.New("pdf: invalid JPEG frame header")
+			}
+			if seg[0] != 8 {
+				return info, ErrUnsupportedJPEG
+			}
+			info.Height = int(seg[1])<<8 | int(seg[2])
+			info.Width = int(seg[3])<<8 | int(seg[4])
+			info.Components = int(seg[5])
+			sof = true
+		}
+	}
+	if !sof {
+		return info, errors.New("pdf: JPEG has no frame header")
+	}
+	if info.Width == 0 || info.Height == 0 {
+		// Height defined by a DNL marker.
+		return info, ErrUnsupportedJPEG
+	}
+	switch info.Components {
+	case 1, 3, 4:
+	default:
+		return info, ErrUnsupportedJPEG
+	}
+	return info, nil
+}
+
+// Returns an image XObject showing JPEG data as is (DCTDecode).
+func JPEG(data []byte) (*Stream, JPEGInfo, error) {
+	info, err := ParseJPEG(data)
+	if err != nil {
+		return nil, info, err
+	}
+	dict := Dict{
+		"Type":             Name("XObject"),
+		"Subtype":          Name("Image"),
+		"Width":            info.Width,
+		"Height":           info.Height,
+		"BitsPerComponent": 8,
+		"Filter":           Name("DCTDecode"),
+	}
+	switch info.Components {
+	case 1:
+		dict["ColorSpace"] = Name("DeviceGray")
+	case 3:
+		// Readers convert from YCbCr as indicated by the JFIF and Adobe
+		// markers.
+		dict["ColorSpace"] = Name("DeviceRGB")
+	case 4:
+		dict["ColorSpace"] = Name("DeviceCMYK")
+		if info.Adobe {
+			dict["Decode"] = Array{1, 0, 1, 0, 1, 0, 1, 0}
+		}
+	}
+	return &Stream{Dict: dict, Data: data}, info, nil
+}
+
+// Reports whether all pixels of img are opaque.
+func Opaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
+
+// Returns an image XObject with img's pixels compressed losslessly using
+// FlateDecode. Gray images are stored as DeviceGray. Transparency is kept
+// as a soft mask.
+func (d *Document) Image(img image.Image) *Stream {
+	b := img.Bounds()
+	dict := Dict{
+		"Type":             Name("XObject"),
+		"Subtype":          Name("Image"),
+		"Width":            b.Dx(),
+		"Height":           b.Dy(),
+		"BitsPerComponent": 8,
+	}
+	if gray, ok := img.(*image.Gray); ok {
+		dict["ColorSpace"] = Name("DeviceGray")
+		data := make([]byte, 0, b.Dx()*b.Dy())
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			off := gray.PixOffset(b.Min.X, y)
+			data = append(data, gray.Pix[off:off+b.Dx()]...)
+		}
+		return Flate(dict, data)
+	}
+	dict["ColorSpace"] = Name("DeviceRGB")
+	opaque := Opaque(img)
+	var pix []byte
+	var stride int
+	if opaque {
+		// Converting to RGBA is fast for common image types.
+		rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+		pix, stride = rgba.Pix, rgba.Stride
+	} else {
+		nrgba := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(nrgba, nrgba.Bounds(), img, b.Min, draw.Src)
+		pix, stride = nrgba.Pix, nrgba.Stride
+	}
+	rgb := make([]byte, 0, b.Dx()*b.Dy()*3)
+	var alpha []byte
+	if !opaque {
+		alpha = make([]byte, 0, b.Dx()*b.Dy())
+	}
+	for y := 0; y < b.Dy(); y++ {
+		row := pix[y*stride : y*stride+b.Dx()*4]
+		for x := 0; x < len(row); x += 4 {
+			rgb = append(rgb, row[x], row[x+1], row[x+2])
+			if !opaque {
+				alpha = append(alpha, row[x+3])
+			}
+		}
+	}
+	if !opaque {
+		dict["SMask"] = d.softMask(b.Dx(), b.Dy(), alpha)
+	}
+	return Flate(dict, rgb)
+}
+
+func (d *Document) softMask(w, h int, alpha []byte) Ref {
+	return d.Add(Flate(Dict{
+		"Type":             Name("XObject"),
+		"Subtype":          Name("Image"),
+		"Width":            w,
+		"Height":           h,
+		"BitsPerComponent": 8,
+		"ColorSpace":       Name("DeviceGray"),
+	}, alpha))
+}
+
+// Returns an image XObject with img encoded as JPEG with the given quality
+// (1 to 100). Transparency is kept as a losslessly compressed soft mask.
+func (d *Document) JPEGImage(img image.Image, quality int) (*Stream, error) {
+	var alpha []byte
+	if !Opaque(img) {
+		// JPEG has no alpha, so encode the colours without it.
+		b := img.Bounds()
+		nrgba := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(nrgba, nrgba.Bounds(), img, b.Min, draw.Src)
+		alpha = make([]byte, 0, b.Dx()*b.Dy())
+		for i := 3; i < len(nrgba.Pix); i += 4 {
+			alpha = append(alpha, nrgba.Pix[i])
+			nrgba.Pix[i] = 0xff
+		}
+		img = nrgba
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	s, info, err := JPEG(buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if alpha != nil {
+		s.Dict["SMask"] = d.softMask(info.Width, info.Height, alpha)
+	}
+	return s, nil
+}
+
+// Returns an image XObject with the pixels of img as indexes into pal (at
+// most 256 colours) compressed using FlateDecode. Colours missing in pal are
+// replaced by the closest one. A palette of only grays is based on
+// DeviceGray. Alpha of pal is ignored; transparency of img is kept as a soft
+// mask.
+func (d *Document) IndexedImage(img *image.NRGBA, pal color.Palette) *Stream {
+	b := img.Bounds()
+	// Fewer bits per index for few colours.
+	bits := 8
+	for _, n := range []int{1, 2, 4} {
+		if len(pal) <= 1<<n {
+			bits = n
+			break
+		}
+	}
+	lookup := make([]byte, 0, len(pal)*3)
+	index := make(map[[3]byte]byte, len(pal))
+	gray := true
+	for i, c := range pal {
+		r, g, b, _ := color.NRGBAModel.Convert(c).(color.NRGBA).RGBA()
+		rgb := [3]byte{byte(r >> 8), byte(g >> 8), byte(b >> 8)}
+		lookup = append(lookup, rgb[:]...)
+		gray = gray && rgb[0] == rgb[1] && rgb[1] == rgb[2]
+		if _, ok := index[rgb]; !ok {
+			index[rgb] = byte(i)
+		}
+	}
+	base := Name("DeviceRGB")
+	if gray {
+		// Only palettes of grays: keep one component per entry.
+		for i := range pal {
+			lookup[i] = lookup[i*3]
+		}
+		lookup = lookup[:len(pal)]
+		base = Name("DeviceGray")
+	}
+	rowLen := (b.Dx()*bits + 7) / 8
+	data := make([]byte, rowLen*b.Dy())
+	opaque := true
+	alpha := make([]byte, 0, b.Dx()*b.Dy())
+	for y := 0; y < b.Dy(); y++ {
+		row := img.Pix[y*img.Stride : y*img.Stride+b.Dx()*4]
+		out := data[y*rowLen : (y+1)*rowLen]
+		for x := 0; x < b.Dx(); x++ {
+			p := row[x*4 : x*4+4]
+			rgb := [3]byte{p[0], p[1], p[2]}
+			i, ok := index[rgb]
+			if !ok {
+				i = byte(pal.Index(color.NRGBA{R: p[0], G: p[1], B: p[2], A: 0xff}))
+				index[rgb] = i
+			}
+			bit := x * bits
+			out[bit/8] |= i << (8 - bits - bit%8)
+			alpha = append(alpha, p[3])
+			opaque = opaque && p[3] == 0xff
+		}
+	}
+	dict := Dict{
+		"Type":             Name("XObject"),
+		"Subtype":          Name("Image"),
+		"Width":            b.Dx(),
+		"Height":           b.Dy(),
+		"BitsPerComponent": bits,
+		"ColorSpace":       Array{Name("Indexed"), base, len(pal) - 1, hexString(lookup)},
+	}
+	if !opaque {
+		dict["SMask"] = d.softMask(b.Dx(), b.Dy(), alpha)
+	}
+	return Flate(dict, data)
+}
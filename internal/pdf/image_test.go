@@ -0,0 +1,143 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"reflect"
+	"testing"
+)
+
+// Returns a marker segment.
+func segment(marker byte, data ...byte) []byte {
+	n := len(data) + 2
+	return append([]byte{0xff, marker, byte(n >> 8), byte(n)}, data...)
+}
+
+// Returns a frame header segment.
+func sof(marker, precision byte, w, h, components int) []byte {
+	data := []byte{precision, byte(h >> 8), byte(h), byte(w >> 8), byte(w), byte(components)}
+	for i := 0; i < components; i++ {
+		data = append(data, byte(i+1), 0x11, 0)
+	}
+	return segment(marker, data...)
+}
+
+// Returns JPEG markers up to the start of a scan, which is left out.
+func jpegHeader(segments ...[]byte) []byte {
+	b := []byte{0xff, 0xd8}
+	for _, s := range segments {
+		b = append(b, s...)
+	}
+	return append(b, segment(0xda, 1, 1, 0, 0, 0x3f, 0)...)
+}
+
+var (
+	jfif  = segment(0xe0, 'J', 'F', 'I', 'F', 0, 1, 1, 0, 0, 1, 0, 1, 0, 0)
+	adobe = segment(0xee, 'A', 'd', 'o', 'b', 'e', 0, 100, 0, 0, 0, 0, 2)
+	dht   = segment(0xc4, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+)
+
+func TestParseJPEG(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       []byte
+		want       JPEGInfo
+		colorSpace Name
+		decode     Array
+	}{
+		{"gray", jpegHeader(jfif, sof(0xc0, 8, 30, 20, 1), dht), JPEGInfo{30, 20, 1, false}, "DeviceGray", nil},
+		{"RGB", jpegHeader(jfif, sof(0xc0, 8, 30, 20, 3), dht), JPEGInfo{30, 20, 3, false}, "DeviceRGB", nil},
+		{"RGB Adobe", jpegHeader(adobe, sof(0xc0, 8, 30, 20, 3)), JPEGInfo{30, 20, 3, true}, "DeviceRGB", nil},
+		{"CMYK", jpegHeader(sof(0xc0, 8, 30, 20, 4)), JPEGInfo{30, 20, 4, false}, "DeviceCMYK", nil},
+		{"CMYK Adobe", jpegHeader(adobe, sof(0xc0, 8, 30, 20, 4)), JPEGInfo{30, 20, 4, true}, "DeviceCMYK", Array{1, 0, 1, 0, 1, 0, 1, 0}},
+		{"extended", jpegHeader(sof(0xc1, 8, 300, 200, 3)), JPEGInfo{300, 200, 3, false}, "DeviceRGB", nil},
+		{"progressive", jpegHeader(jfif, sof(0xc2, 8, 65535, 1, 3)), JPEGInfo{65535, 1, 3, false}, "DeviceRGB", nil},
+		// An APP14 marker of another application.
+		{"not Adobe", jpegHeader(segment(0xee, 'A', 'd', 'o'), sof(0xc0, 8, 1, 1, 4)), JPEGInfo{1, 1, 4, false}, "DeviceCMYK", nil},
+		// Fill bytes before markers.
+		{"fill", append([]byte{0xff, 0xd8, 0xff, 0xff}, jpegHeader(sof(0xc0, 8, 2, 2, 1))[3:]...), JPEGInfo{2, 2, 1, false}, "DeviceGray", nil},
+	}
+	for _, tt := range tests {
+		s, info, err := JPEG(tt.data)
+		if err != nil {
+			t.Errorf("%v: %v", tt.name, err)
+			continue
+		}
+		if info != tt.want {
+			t.Errorf("%v: got %+v, want %+v", tt.name, info, tt.want)
+		}
+		if s.Dict["ColorSpace"] != tt.colorSpace || s.Dict["Width"] != tt.want.Width || s.Dict["Height"] != tt.want.Height ||
+			s.Dict["Filter"] != Name("DCTDecode") || !bytes.Equal(s.Data, tt.data) {
+			t.Errorf("%v: got image %v", tt.name, s.Dict)
+		}
+		if decode, _ := s.Dict["Decode"].(Array); !reflect.DeepEqual(decode, tt.decode) {
+			t.Errorf("%v: Decode %v, want %v", tt.name, decode, tt.decode)
+		}
+	}
+}
+
+func TestParseJPEGEncoded(t *testing.T) {
+	for _, img := range []image.Image{image.NewGray(image.Rect(0, 0, 17, 9)), image.NewRGBA(image.Rect(0, 0, 17, 9))} {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, nil); err != nil {
+			t.Fatal(err)
+		}
+		want := JPEGInfo{17, 9, 3, false}
+		if _, ok := img.(*image.Gray); ok {
+			want.Components = 1
+		}
+		if info, err := ParseJPEG(buf.Bytes()); err != nil || info != want {
+			t.Errorf("%T: got %+v, %v; want %+v", img, info, err, want)
+		}
+	}
+}
+
+func TestParseJPEGUnsupported(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"12-bit", jpegHeader(sof(0xc1, 12, 30, 20, 3))},
+		{"lossless", jpegHeader(sof(0xc3, 8, 30, 20, 3))},
+		{"hierarchical", jpegHeader(sof(0xc5, 8, 30, 20, 3))},
+		{"arithmetic", jpegHeader(sof(0xc9, 8, 30, 20, 3))},
+		{"arithmetic progressive", jpegHeader(sof(0xca, 8, 30, 20, 3))},
+		{"DNL height", jpegHeader(sof(0xc0, 8, 30, 0, 3))},
+		{"2 components", jpegHeader(sof(0xc0, 8, 30, 20, 2))},
+	}
+	for _, tt := range tests {
+		if _, err := ParseJPEG(tt.data); !errors.Is(err, ErrUnsupportedJPEG) {
+			t.Errorf("%v: got error %v, want %v", tt.name, err, ErrUnsupportedJPEG)
+		}
+	}
+}
+
+func TestParseJPEGInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"PNG", []byte("\x89PNG\r\n\x1a\n")},
+		{"no frame header", jpegHeader(jfif, dht)},
+		{"short frame header", jpegHeader(segment(0xc0, 8, 0, 20, 0, 30))},
+		{"short segment length", append([]byte{0xff, 0xd8, 0xff, 0xe0, 0, 1}, jpegHeader(sof(0xc0, 8, 1, 1, 1))[2:]...)},
+		{"end of image", []byte{0xff, 0xd8, 0xff, 0xd9}},
+	}
+	for _, tt := range tests {
+		if _, err := ParseJPEG(tt.data); err == nil || errors.Is(err, ErrUnsupportedJPEG) {
+			t.Errorf("%v: got error %v, want an invalid JPEG", tt.name, err)
+		}
+	}
+
+	// Every truncation before the start of the scan.
+	data := jpegHeader(jfif, adobe, sof(0xc2, 8, 30, 20, 3), dht)
+	sos := bytes.LastIndex(data, []byte{0xff, 0xda})
+	for n := 0; n <= sos+1; n++ {
+		if _, err := ParseJPEG(data[:n]); err == nil || errors.Is(err, ErrUnsupportedJPEG) {
+			t.Errorf("truncated to %v bytes: got error %v, want an invalid JPEG", n, err)
+		}
+	}
+}
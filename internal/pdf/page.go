@@ -0,0 +1,85 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+)
+
+// Builds the content of a page. Coordinates are in points from the top
+// left of the page.
+type Page struct {
+	W, H float64
+
+	content  bytes.Buffer
+	xobjects Dict
+	fonts    Dict
+}
+
+func NewPage(w, h float64) *Page {
+	return &Page{
+		W:        w,
+		H:        h,
+		xobjects: Dict{},
+		fonts:    Dict{},
+	}
+}
+
+func (p *Page) op(format string, args ...any) {
+	for i, a := range args {
+		if f, ok := a.(float64); ok {
+			args[i] = formatNumber(f)
+		}
+	}
+	fmt.Fprintf(&p.content, format+"\n", args...)
+}
+
+// Fills a rectangle with c, ignoring its alpha.
+func (p *Page) FillRect(x, y, w, h float64, c color.Color) {
+	r, g, b, _ := c.RGBA()
+	p.op("%v %v %v rg", float64(r)/0xffff, float64(g)/0xffff, float64(b)/0xffff)
+	p.op("%v %v %v %v re f", x, p.H-y-h, w, h)
+}
+
+// Draws the image XObject img into the rectangle, clipped to the page.
+func (p *Page) DrawImage(img Ref, x, y, w, h float64) {
+	name := Name(fmt.Sprintf("Im%v", len(p.xobjects)+1))
+	p.xobjects[name] = img
+	p.op("q")
+	p.op("0 0 %v %v re W n", p.W, p.H)
+	p.op("%v 0 0 %v %v %v cm", w, h, x, p.H-y-h)
+	p.op("/%v Do", string(name))
+	p.op("Q")
+}
+
+// Shows text with its baseline starting at x, y.
+func (p *Page) Text(f *Font, size, x, y float64, text string) {
+	name := Name("")
+	for k, v := range p.fonts {
+		if v == f.Ref {
+			name = k
+		}
+	}
+	if name == "" {
+		name = Name(fmt.Sprintf("F%v", len(p.fonts)+1))
+		p.fonts[name] = f.Ref
+	}
+	p.op("BT")
+	p.op("0 g")
+	p.op("/%v %v Tf", string(name), size)
+	p.op("%v %v Td", x, p.H-y)
+	p.op("%v Tj", f.Encode(text))
+	p.op("ET")
+}
+
+// Adds the page to d. It must not be changed afterwards.
+func (d *Document) AddPage(p *Page) Ref {
+	res := Dict{}
+	if len(p.xobjects) > 0 {
+		res["XObject"] = p.xobjects
+	}
+	if len(p.fonts) > 0 {
+		res["Font"] = p.fonts
+	}
+	return d.addPage(p.W, p.H, p.content.Bytes(), res)
+}
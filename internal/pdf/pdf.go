@@ -0,0 +1,390 @@
+// Package pdf writes PDF files.
+//
+// It covers only what pic4pdf exports: pages with content streams, image
+// XObjects (including passed-through JPEG data), embedded TrueType fonts,
+// outlines, document metadata, AES-256 encryption, signatures and what
+// PDF/A-2b needs.
+package pdf
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Values of PDF objects are nil, bool, int, float64, Name, String, Array,
+// Dict, Ref or, only as indirect objects, *Stream.
+type (
+	Name   string
+	String string
+	Array  []any
+	Dict   map[Name]any
+	// Number of an indirect object.
+	Ref int
+)
+
+type Stream struct {
+	Dict Dict
+	// Encoded data; the Length is set when writing.
+	Data []byte
+}
+
+// Returns a stream with data compressed using FlateDecode.
+func Flate(dict Dict, data []byte) *Stream {
+	var b bytes.Buffer
+	zw := zlib.NewWriter(&b)
+	zw.Write(data)
+	zw.Close()
+	if dict == nil {
+		dict = Dict{}
+	}
+	dict["Filter"] = Name("FlateDecode")
+	return &Stream{Dict: dict, Data: b.Bytes()}
+}
+
+// An entry of the document outline (bookmarks).
+type Outline struct {
+	Title    string
+	Page     Ref
+	Children []*Outline
+}
+
+type Document struct {
+	// Info dictionary.
+	Info Dict
+	// Entries added to the catalog, e.g. Metadata.
+	Catalog Dict
+	// Top-level outline entries.
+	Outlines []*Outline
+	// If set, strings and streams are encrypted.
+	Encryption *Encryption
+	// If set, the document is signed.
+	Signature *Signature
+
+	objects []any
+	pages   []Ref
+	fonts   []*Font
+	// Reserved for the page tree.
+	pagesRef Ref
+}
+
+func New() *Document {
+	d := &Document{
+		Info:    Dict{},
+		Catalog: Dict{},
+	}
+	d.pagesRef = d.Reserve()
+	return d
+}
+
+// Adds an indirect object.
+func (d *Document) Add(obj any) Ref {
+	d.objects = append(d.objects, obj)
+	return Ref(len(d.objects))
+}
+
+// Reserves an indirect object, which must be set by Set before writing.
+func (d *Document) Reserve() Ref {
+	return d.Add(nil)
+}
+
+func (d *Document) Set(ref Ref, obj any) {
+	d.objects[ref-1] = obj
+}
+
+// Adds a page of w by h points with the given content stream and resources.
+func (d *Document) addPage(w, h float64, content []byte, resources Dict) Ref {
+	ref := d.Add(Dict{
+		"Type":      Name("Page"),
+		"Parent":    d.pagesRef,
+		"MediaBox":  Array{0, 0, w, h},
+		"Resources": resources,
+		"Contents":  d.Add(Flate(nil, content)),
+	})
+	d.pages = append(d.pages, ref)
+	return ref
+}
+
+func (d *Document) NumPages() int {
+	return len(d.pages)
+}
+
+// Adds outline items for entries below parent, returning the first and last
+// item and the number of visible descendants.
+func (d *Document) addOutlines(entries []*Outline, parent Ref) (first, last Ref, count int) {
+	refs := make([]Ref, len(entries))
+	for i := range entries {
+		refs[i] = d.Reserve()
+	}
+	for i, e := range entries {
+		item := Dict{
+			"Title":  String(e.Title),
+			"Parent": parent,
+			"Dest":   Array{e.Page, Name("Fit")},
+		}
+		if i > 0 {
+			item["Prev"] = refs[i-1]
+		}
+		if i < len(entries)-1 {
+			item["Next"] = refs[i+1]
+		}
+		if len(e.Children) > 0 {
+			f, l, n := d.addOutlines(e.Children, refs[i])
+			item["First"], item["Last"], item["Count"] = f, l, n
+			count += n
+		}
+		d.Set(refs[i], item)
+		count++
+	}
+	return refs[0], refs[len(refs)-1], count
+}
+
+// Writes the document. The document must not be changed afterwards.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	for _, f := range d.fonts {
+		if err := f.write(d); err != nil {
+			return 0, err
+		}
+	}
+	kids := make(Array, len(d.pages))
+	for i, p := range d.pages {
+		kids[i] = p
+	}
+	d.Set(d.pagesRef, Dict{"Type": Name("Pages"), "Kids": kids, "Count": len(d.pages)})
+	catalog := Dict{"Type": Name("Catalog"), "Pages": d.pagesRef}
+	for k, v := range d.Catalog {
+		catalog[k] = v
+	}
+	if len(d.Outlines) > 0 {
+		outlines := d.Reserve()
+		first, last, n := d.addOutlines(d.Outlines, outlines)
+		d.Set(outlines, Dict{"Type": Name("Outlines"), "First": first, "Last": last, "Count": n})
+		catalog["Outlines"] = outlines
+		catalog["PageMode"] = Name("UseOutlines")
+	}
+	var sigRef Ref
+	if d.Signature != nil {
+		var err error
+		if sigRef, err = d.addSignature(catalog); err != nil {
+			return 0, err
+		}
+	}
+	var enc *encrypter
+	var encRef Ref
+	if d.Encryption != nil {
+		var dict Dict
+		var err error
+		if dict, enc, err = d.Encryption.setup(); err != nil {
+			return 0, err
+		}
+		encRef = d.Add(dict)
+		// AES-256 is part of PDF 2.0 and of this extension to PDF 1.7.
+		catalog["Extensions"] = Dict{"ADBE": Dict{"BaseVersion": Name("1.7"), "ExtensionLevel": 8}}
+	}
+	root := d.Add(catalog)
+	info := d.Add(d.Info)
+
+	dst := w
+	var signed bytes.Buffer
+	if sigRef != 0 {
+		// The document is signed once it is complete.
+		dst = &signed
+	}
+	cw := &countWriter{w: bufio.NewWriter(dst)}
+	// The file ID is derived from the content.
+	hash := md5.New()
+	out := io.MultiWriter(cw, hash)
+	// Binary comment marking the file as binary.
+	fmt.Fprint(out, "%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int64, len(d.objects))
+	var b bytes.Buffer
+	for i, obj := range d.objects {
+		offsets[i] = cw.n
+		if enc != nil && Ref(i+1) != encRef {
+			var err error
+			if obj, err = enc.value(obj); err != nil {
+				return cw.n, err
+			}
+		}
+		b.Reset()
+		fmt.Fprintf(&b, "%v 0 obj\n", i+1)
+		if s, ok := obj.(*Stream); ok {
+			dict := Dict{}
+			for k, v := range s.Dict {
+				dict[k] = v
+			}
+			dict["Length"] = len(s.Data)
+			writeValue(&b, dict)
+			b.WriteString("\nstream\n")
+			b.Write(s.Data)
+			b.WriteString("\nendstream")
+		} else {
+			writeValue(&b, obj)
+		}
+		b.WriteString("\nendobj\n")
+		out.Write(b.Bytes())
+	}
+	id := String(hash.Sum(nil))
+	xref := cw.n
+	fmt.Fprintf(cw, "xref\n0 %v\n0000000000 65535 f \n", len(d.objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(cw, "%010d 00000 n \n", off)
+	}
+	trailer := Dict{
+		"Size": len(d.objects) + 1,
+		"Root": root,
+		"Info": info,
+		"ID":   Array{hexString(id), hexString(id)},
+	}
+	if enc != nil {
+		trailer["Encrypt"] = encRef
+	}
+	b.Reset()
+	writeValue(&b, trailer)
+	fmt.Fprintf(cw, "trailer\n%s\nstartxref\n%v\n%%%%EOF\n", b.Bytes(), xref)
+	if cw.err != nil {
+		return cw.n, cw.err
+	}
+	if err := cw.w.Flush(); err != nil || sigRef == 0 {
+		return cw.n, err
+	}
+	if err := d.Signature.sign(signed.Bytes(), int(offsets[sigRef-1])); err != nil {
+		return 0, err
+	}
+	n, err := w.Write(signed.Bytes())
+	return int64(n), err
+}
+
+type countWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	cw.err = err
+	return n, err
+}
+
+// A string written in hexadecimal.
+type hexString String
+
+// Formats a number with at most 4 decimals.
+func formatNumber(v float64) string {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return "0"
+	}
+	s := strings.TrimRight(strconv.FormatFloat(v, 'f', 4, 64), "0")
+	s = strings.TrimSuffix(s, ".")
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// Reports whether s can be written as a literal string without encoding.
+func isPlain(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// Encodes a text string as UTF-16BE with byte order mark, unless it is
+// plain ASCII.
+func textString(s string) []byte {
+	if isPlain(s) {
+		return []byte(s)
+	}
+	b := []byte{0xfe, 0xff}
+	for _, c := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(c>>8), byte(c))
+	}
+	return b
+}
+
+func writeValue(b *bytes.Buffer, v any) {
+	switch v := v.(type) {
+	case nil:
+		b.WriteString("null")
+	case bool:
+		b.WriteString(strconv.FormatBool(v))
+	case int:
+		b.WriteString(strconv.Itoa(v))
+	case float64:
+		b.WriteString(formatNumber(v))
+	case Name:
+		b.WriteByte('/')
+		for i := 0; i < len(v); i++ {
+			c := v[i]
+			if c <= ' ' || c > '~' || bytes.IndexByte([]byte("#()<>[]{}/%"), c) != -1 {
+				fmt.Fprintf(b, "#%02X", c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	case String:
+		s := textString(string(v))
+		if !isPlain(string(s)) {
+			writeValue(b, hexString(s))
+			return
+		}
+		b.WriteByte('(')
+		for _, c := range s {
+			if c == '(' || c == ')' || c == '\\' {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(c)
+		}
+		b.WriteByte(')')
+	case hexString:
+		fmt.Fprintf(b, "<%X>", string(v))
+	case Array:
+		b.WriteByte('[')
+		for i, e := range v {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			writeValue(b, e)
+		}
+		b.WriteByte(']')
+	case Dict:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, string(k))
+		}
+		sort.Strings(keys)
+		b.WriteString("<<")
+		for _, k := range keys {
+			writeValue(b, Name(k))
+			b.WriteByte(' ')
+			writeValue(b, v[Name(k)])
+		}
+		b.WriteString(">>")
+	case Ref:
+		fmt.Fprintf(b, "%v 0 R", int(v))
+	case signatureContents:
+		b.WriteByte('<')
+		b.Write(bytes.Repeat([]byte{'0'}, 2*int(v)))
+		b.WriteByte('>')
+	case signatureByteRange:
+		fmt.Fprintf(b, byteRangeFormat, 0, 0, 0)
+	default:
+		panic(fmt.Sprintf("pdf: unsupported value %T", v))
+	}
+}
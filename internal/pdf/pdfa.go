@@ -0,0 +1,120 @@
+package pdf
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Limits of PDF/A-2 (ISO 19005-2, 6.1.13).
+const (
+	maxStringLen = 32767
+	maxNameLen   = 127
+	maxObjects   = 8388607
+)
+
+// Checks the parts of the PDF/A-2b requirements which depend on what was
+// added to d, before it is written. Returns the reasons it isn't compliant,
+// if any.
+func (d *Document) CheckPDFA2B() []string {
+	var reasons []string
+	add := func(format string, args ...any) {
+		r := fmt.Sprintf(format, args...)
+		if !slices.Contains(reasons, r) {
+			reasons = append(reasons, r)
+		}
+	}
+	if d.Encryption != nil {
+		add("it is encrypted")
+	}
+	if _, ok := d.Catalog["Metadata"]; !ok {
+		add("it has no XMP metadata")
+	}
+	hasRGBIntent := false
+	if intents, ok := d.Catalog["OutputIntents"].(Array); ok {
+		for _, oi := range intents {
+			if oi, ok := oi.(Dict); ok && oi["S"] == Name("GTS_PDFA1") {
+				if s, ok := d.object(oi["DestOutputProfile"]).(*Stream); ok && s.Dict["N"] == 3 {
+					hasRGBIntent = true
+				}
+			}
+		}
+	}
+	if !hasRGBIntent {
+		add("it has no RGB output intent")
+	}
+	if len(d.objects) > maxObjects {
+		add("it has more than %v objects", maxObjects)
+	}
+
+	var check func(v any)
+	check = func(v any) {
+		switch v := v.(type) {
+		case int:
+			if v > math.MaxInt32 || v < math.MinInt32 {
+				add("a number is too large")
+			}
+		case String:
+			if len(textString(string(v))) > maxStringLen {
+				add("a text is longer than %v bytes", maxStringLen)
+			}
+		case hexString:
+			if len(v) > maxStringLen {
+				add("a string is longer than %v bytes", maxStringLen)
+			}
+		case Name:
+			if len(v) > maxNameLen {
+				add("a name is longer than %v bytes", maxNameLen)
+			}
+		case Array:
+			for _, e := range v {
+				check(e)
+			}
+		case Dict:
+			for k, e := range v {
+				check(k)
+				check(e)
+			}
+			if v["Subtype"] == Name("Image") {
+				if _, ok := v["SMask"]; ok {
+					add("an image has transparency")
+				}
+				if v["ColorSpace"] == Name("DeviceCMYK") {
+					add("an image has CMYK colours")
+				}
+				if v["Interpolate"] == true {
+					add("an image is interpolated")
+				}
+			}
+		case *Stream:
+			check(v.Dict)
+		}
+	}
+	for _, obj := range d.objects {
+		check(obj)
+	}
+	check(d.Info)
+	check(d.Catalog)
+
+	var missing []string
+	for _, f := range d.fonts {
+		for r := range f.missing {
+			missing = append(missing, fmt.Sprintf("%q", r))
+		}
+	}
+	if len(missing) > 0 {
+		slices.Sort(missing)
+		missing = slices.Compact(missing)
+		add("text uses characters the font lacks: %v", strings.Join(missing, " "))
+	}
+	return reasons
+}
+
+// Returns the object ref refers to, or v itself if it isn't a Ref.
+func (d *Document) object(v any) any {
+	if ref, ok := v.(Ref); ok && ref > 0 && int(ref) <= len(d.objects) {
+		return d.objects[ref-1]
+	}
+	return v
+}
@@ -0,0 +1,123 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// A digital signature of the whole document, made when it is written
+// (subfilter ETSI.CAdES.detached, as in PAdES).
+type Signature struct {
+	// Name of the signer.
+	Name string
+	Time time.Time
+	// The page of the signature field.
+	Page Ref
+	// If set, the form XObject shown as a stamp in the given rectangle of
+	// Page, in points from its top left. Otherwise the signature is
+	// invisible.
+	Appearance Ref
+	X, Y, W, H float64
+	// Returns a detached CMS signature of data, of at most Size bytes.
+	Sign func(data []byte) ([]byte, error)
+	Size int
+}
+
+// Placeholders for the parts of the signature dictionary which are filled in
+// after writing. They are never encrypted.
+type (
+	// Hexadecimal string of the given number of zero bytes.
+	signatureContents int
+	// Array of the offsets and lengths of the signed parts of the file.
+	signatureByteRange struct{}
+)
+
+const byteRangeFormat = "[0 %010d %010d %010d]"
+
+// Adds a form XObject with the content of p, e.g. for a signature stamp.
+func (d *Document) AddForm(p *Page) Ref {
+	res := Dict{}
+	if len(p.xobjects) > 0 {
+		res["XObject"] = p.xobjects
+	}
+	if len(p.fonts) > 0 {
+		res["Font"] = p.fonts
+	}
+	return d.Add(Flate(Dict{
+		"Type":      Name("XObject"),
+		"Subtype":   Name("Form"),
+		"BBox":      Array{0, 0, p.W, p.H},
+		"Resources": res,
+	}, p.content.Bytes()))
+}
+
+// Adds the signature dictionary and its field, returning the reference of
+// the dictionary.
+func (d *Document) addSignature(catalog Dict) (Ref, error) {
+	s := d.Signature
+	page, ok := d.object(s.Page).(Dict)
+	if !ok {
+		return 0, errors.New("pdf: signature on a missing page")
+	}
+	sig := d.Add(Dict{
+		"Type":      Name("Sig"),
+		"Filter":    Name("Adobe.PPKLite"),
+		"SubFilter": Name("ETSI.CAdES.detached"),
+		"Name":      String(s.Name),
+		"M":         String(s.Time.UTC().Format("D:20060102150405Z")),
+		"Contents":  signatureContents(s.Size),
+		"ByteRange": signatureByteRange{},
+	})
+	field := Dict{
+		"Type":    Name("Annot"),
+		"Subtype": Name("Widget"),
+		"FT":      Name("Sig"),
+		"T":       String("Signature"),
+		"V":       sig,
+		"P":       s.Page,
+		// Printed and locked.
+		"F":    4 | 128,
+		"Rect": Array{0, 0, 0, 0},
+	}
+	if s.Appearance != 0 {
+		h := page["MediaBox"].(Array)[3].(float64)
+		field["Rect"] = Array{s.X, h - s.Y - s.H, s.X + s.W, h - s.Y}
+		field["AP"] = Dict{"N": s.Appearance}
+	}
+	ref := d.Add(field)
+	annots, _ := page["Annots"].(Array)
+	page["Annots"] = append(annots, ref)
+	catalog["AcroForm"] = Dict{
+		"Fields": Array{ref},
+		// Signatures exist; only append.
+		"SigFlags": 3,
+	}
+	return sig, nil
+}
+
+// Fills in the byte range and signature of the document written to data,
+// where the signature dictionary starts at offset.
+func (s *Signature) sign(data []byte, offset int) error {
+	obj := data[offset:]
+	contents := bytes.Index(obj, []byte("/Contents <"))
+	byteRange := bytes.Index(obj, []byte("/ByteRange "))
+	if contents == -1 || byteRange == -1 {
+		return errors.New("pdf: signature placeholders not found")
+	}
+	start := offset + contents + len("/Contents ")
+	end := start + 2 + 2*s.Size
+	rng := fmt.Sprintf(byteRangeFormat, start, end, len(data)-end)
+	copy(data[offset+byteRange+len("/ByteRange "):], rng)
+	sig, err := s.Sign(append(data[:start:start], data[end:]...))
+	if err != nil {
+		return err
+	}
+	if len(sig) > s.Size {
+		return fmt.Errorf("pdf: signature of %v bytes exceeds the %v bytes reserved", len(sig), s.Size)
+	}
+	hex.Encode(data[start+1:], sig)
+	return nil
+}
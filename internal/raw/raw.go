@@ -0,0 +1,283 @@
+// Package raw reads camera RAW files (DNG, CR2, NEF, ARW).
+//
+// Instead of developing the sensor data, the largest JPEG preview embedded
+// by the camera is used. These previews are usually full-size.
+package raw
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	ErrNotTIFF   = errors.New("raw: not a TIFF-based RAW file")
+	ErrNoPreview = errors.New("raw: no embedded JPEG preview found")
+)
+
+var extensions = []string{".dng", ".cr2", ".nef", ".arw"}
+
+// Reports whether name has the extension of a supported RAW format.
+func IsRaw(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, e := range extensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	tagCompression     = 0x0103
+	tagStripOffsets    = 0x0111
+	tagOrientation     = 0x0112
+	tagStripByteCounts = 0x0117
+	tagSubIFDs         = 0x014a
+	tagJPEGOffset      = 0x0201
+	tagJPEGLength      = 0x0202
+	compressionOldJPEG = 6
+	compressionJPEG    = 7
+	maxIFDs            = 64
+	maxEntriesPerIFD   = 1024
+	typeShort          = 3
+	typeLong           = 4
+	typeIFD            = 13
+)
+
+type ifdEntry struct {
+	typ   uint16
+	count uint32
+	// Value or offset of the value.
+	value [4]byte
+}
+
+type tiffReader struct {
+	r  io.ReaderAt
+	bo binary.ByteOrder
+}
+
+// Reads all integer values of e.
+func (t *tiffReader) uints(e ifdEntry) ([]uint32, error) {
+	var sz int
+	switch e.typ {
+	case typeShort:
+		sz = 2
+	case typeLong, typeIFD:
+		sz = 4
+	default:
+		return nil, nil
+	}
+	if e.count > 1<<16 {
+		return nil, ErrNotTIFF
+	}
+	buf := e.value[:]
+	if n := int(e.count) * sz; n > 4 {
+		buf = make([]byte, n)
+		if _, err := t.r.ReadAt(buf, int64(t.bo.Uint32(e.value[:]))); err != nil {
+			return nil, err
+		}
+	}
+	res := make([]uint32, e.count)
+	for i := range res {
+		if sz == 2 {
+			res[i] = uint32(t.bo.Uint16(buf[i*2:]))
+		} else {
+			res[i] = t.bo.Uint32(buf[i*4:])
+		}
+	}
+	return res, nil
+}
+
+// Returns the first integer value of the tag, or 0 if missing.
+func (t *tiffReader) uint(ifd map[uint16]ifdEntry, tag uint16) uint32 {
+	e, ok := ifd[tag]
+	if !ok {
+		return 0
+	}
+	v, err := t.uints(e)
+	if err != nil || len(v) == 0 {
+		return 0
+	}
+	return v[0]
+}
+
+func (t *tiffReader) readIFD(off int64) (ifd map[uint16]ifdEntry, next int64, err error) {
+	var b [12]byte
+	if _, err := t.r.ReadAt(b[:2], off); err != nil {
+		return nil, 0, err
+	}
+	n := int(t.bo.Uint16(b[:2]))
+	if n > maxEntriesPerIFD {
+		return nil, 0, ErrNotTIFF
+	}
+	ifd = make(map[uint16]ifdEntry, n)
+	for i := 0; i < n; i++ {
+		if _, err := t.r.ReadAt(b[:], off+2+int64(i)*12); err != nil {
+			return nil, 0, err
+		}
+		e := ifdEntry{
+			typ:   t.bo.Uint16(b[2:]),
+			count: t.bo.Uint32(b[4:]),
+		}
+		copy(e.value[:], b[8:])
+		ifd[t.bo.Uint16(b[:])] = e
+	}
+	if _, err := t.r.ReadAt(b[:4], off+2+int64(n)*12); err != nil {
+		return nil, 0, err
+	}
+	return ifd, int64(t.bo.Uint32(b[:4])), nil
+}
+
+type preview struct {
+	off, len int64
+	pixels   int
+}
+
+// Extracts the largest embedded JPEG preview from the RAW file in r.
+// orientation is the EXIF orientation (1-8) of the RAW.
+func ExtractJPEG(r io.ReaderAt, size int64) (jpg []byte, orientation int, err error) {
+	t := &tiffReader{r: r}
+	var hdr [8]byte
+	if _, err := r.ReadAt(hdr[:], 0); err != nil {
+		return nil, 0, ErrNotTIFF
+	}
+	switch string(hdr[:2]) {
+	case "II":
+		t.bo = binary.LittleEndian
+	case "MM":
+		t.bo = binary.BigEndian
+	default:
+		return nil, 0, ErrNotTIFF
+	}
+	if t.bo.Uint16(hdr[2:]) != 42 {
+		return nil, 0, ErrNotTIFF
+	}
+
+	var best preview
+	orientation = 1
+	visited := make(map[int64]bool)
+	queue := []int64{int64(t.bo.Uint32(hdr[4:]))}
+	for len(queue) > 0 && len(visited) < maxIFDs {
+		off := queue[0]
+		queue = queue[1:]
+		if off <= 0 || off >= size || visited[off] {
+			continue
+		}
+		isIFD0 := len(visited) == 0
+		visited[off] = true
+		ifd, next, err := t.readIFD(off)
+		if err != nil {
+			continue
+		}
+		queue = append(queue, next)
+		if e, ok := ifd[tagSubIFDs]; ok {
+			if subs, err := t.uints(e); err == nil {
+				for _, s := range subs {
+					queue = append(queue, int64(s))
+				}
+			}
+		}
+		if isIFD0 {
+			if o := int(t.uint(ifd, tagOrientation)); o >= 1 && o <= 8 {
+				orientation = o
+			}
+		}
+
+		var candidates []preview
+		if jOff, jLen := t.uint(ifd, tagJPEGOffset), t.uint(ifd, tagJPEGLength); jOff != 0 && jLen != 0 {
+			candidates = append(candidates, preview{off: int64(jOff), len: int64(jLen)})
+		}
+		if c := t.uint(ifd, tagCompression); c == compressionOldJPEG || c == compressionJPEG {
+			offs, _ := t.uints(ifd[tagStripOffsets])
+			lens, _ := t.uints(ifd[tagStripByteCounts])
+			if len(offs) == 1 && len(lens) == 1 {
+				candidates = append(candidates, preview{off: int64(offs[0]), len: int64(lens[0])})
+			}
+		}
+		for _, c := range candidates {
+			if c.off <= 0 || c.len <= 0 || c.off+c.len > size {
+				continue
+			}
+			// Lossless JPEG sensor data is rejected here, as image/jpeg
+			// does not support it.
+			cfg, err := jpeg.DecodeConfig(io.NewSectionReader(r, c.off, c.len))
+			if err != nil {
+				continue
+			}
+			c.pixels = cfg.Width * cfg.Height
+			if c.pixels > best.pixels {
+				best = c
+			}
+		}
+	}
+	if best.pixels == 0 {
+		return nil, 0, ErrNoPreview
+	}
+	jpg = make([]byte, best.len)
+	if _, err := r.ReadAt(jpg, best.off); err != nil {
+		return nil, 0, err
+	}
+	return jpg, orientation, nil
+}
+
+// Decodes the largest embedded JPEG preview of the RAW file in r,
+// rotated according to the RAW's orientation.
+func Decode(r io.ReaderAt, size int64) (image.Image, error) {
+	jpg, orientation, err := ExtractJPEG(r, size)
+	if err != nil {
+		return nil, err
+	}
+	img, err := jpeg.Decode(bytes.NewReader(jpg))
+	if err != nil {
+		return nil, err
+	}
+	return Orient(img, orientation), nil
+}
+
+// Transforms img according to the EXIF orientation o, so that it is
+// displayed upright.
+func Orient(img image.Image, o int) image.Image {
+	if o <= 1 || o > 8 {
+		return img
+	}
+	b := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Rect, img, b.Min, draw.Src)
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if o >= 5 {
+		// Orientations 5-8 swap width and height.
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch o {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			copy(dst.Pix[dst.PixOffset(dx, dy):][:4], src.Pix[src.PixOffset(x, y):][:4])
+		}
+	}
+	return dst
+}
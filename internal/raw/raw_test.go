@@ -0,0 +1,299 @@
+package raw
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+type entry struct {
+	tag, typ uint16
+	vals     []uint32
+}
+
+// Builds TIFF files.
+type tiffWriter struct {
+	bo  binary.ByteOrder
+	buf []byte
+}
+
+func newTIFF(bo binary.ByteOrder) *tiffWriter {
+	w := &tiffWriter{bo: bo, buf: make([]byte, 8)}
+	if bo == binary.LittleEndian {
+		copy(w.buf, "II")
+	} else {
+		copy(w.buf, "MM")
+	}
+	bo.PutUint16(w.buf[2:], 42)
+	return w
+}
+
+// Appends data at an even offset and returns the offset.
+func (w *tiffWriter) add(data []byte) uint32 {
+	if len(w.buf)%2 == 1 {
+		w.buf = append(w.buf, 0)
+	}
+	off := uint32(len(w.buf))
+	w.buf = append(w.buf, data...)
+	return off
+}
+
+// Appends an IFD without a next IFD and returns its offset.
+func (w *tiffWriter) ifd(entries ...entry) uint32 {
+	b := make([]byte, 2+12*len(entries)+4)
+	w.bo.PutUint16(b, uint16(len(entries)))
+	for i, e := range entries {
+		sz := 4
+		if e.typ == typeShort {
+			sz = 2
+		}
+		vals := make([]byte, max(4, sz*len(e.vals)))
+		for j, v := range e.vals {
+			if sz == 2 {
+				w.bo.PutUint16(vals[j*2:], uint16(v))
+			} else {
+				w.bo.PutUint32(vals[j*4:], v)
+			}
+		}
+		if len(vals) > 4 {
+			w.bo.PutUint32(vals, w.add(vals))
+		}
+		e2 := b[2+12*i:]
+		w.bo.PutUint16(e2, e.tag)
+		w.bo.PutUint16(e2[2:], e.typ)
+		w.bo.PutUint32(e2[4:], uint32(len(e.vals)))
+		copy(e2[8:12], vals)
+	}
+	return w.add(b)
+}
+
+// Sets the value of entry i of the IFD at off, which must fit in the entry.
+func (w *tiffWriter) setValue(off uint32, i int, v uint32) {
+	w.bo.PutUint32(w.buf[off+2+12*uint32(i)+8:], v)
+}
+
+// Sets the IFD following the one at off.
+func (w *tiffWriter) setNext(off, next uint32) {
+	n := w.bo.Uint16(w.buf[off:])
+	w.bo.PutUint32(w.buf[off+2+12*uint32(n):], next)
+}
+
+func testJPEG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	var b bytes.Buffer
+	if err := jpeg.Encode(&b, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// Entries of an embedded JPEG preview, either in JPEGInterchangeFormat
+// or in a single strip.
+func jpegEntries(w *tiffWriter, jpg []byte, strip bool) []entry {
+	off := w.add(jpg)
+	if strip {
+		return []entry{
+			{tagCompression, typeShort, []uint32{compressionJPEG}},
+			{tagStripOffsets, typeLong, []uint32{off}},
+			{tagStripByteCounts, typeLong, []uint32{uint32(len(jpg))}},
+		}
+	}
+	return []entry{
+		{tagJPEGOffset, typeLong, []uint32{off}},
+		{tagJPEGLength, typeLong, []uint32{uint32(len(jpg))}},
+	}
+}
+
+func TestExtractJPEG(t *testing.T) {
+	small, large := testJPEG(t, 16, 8), testJPEG(t, 64, 32)
+	for _, bo := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		tests := []struct {
+			name  string
+			build func(w *tiffWriter) (ifd0 uint32)
+			want  []byte
+		}{
+			{"sub IFD", func(w *tiffWriter) uint32 {
+				sub := w.ifd(jpegEntries(w, large, true)...)
+				return w.ifd(append(jpegEntries(w, small, false),
+					entry{tagOrientation, typeShort, []uint32{6}},
+					entry{tagSubIFDs, typeIFD, []uint32{sub}})...)
+			}, large},
+			{"largest first", func(w *tiffWriter) uint32 {
+				sub := w.ifd(jpegEntries(w, small, true)...)
+				return w.ifd(append(jpegEntries(w, large, false),
+					entry{tagOrientation, typeShort, []uint32{6}},
+					entry{tagSubIFDs, typeLong, []uint32{sub}})...)
+			}, large},
+			// The first sub IFD links to the one with the largest preview.
+			{"sub IFD chain", func(w *tiffWriter) uint32 {
+				last := w.ifd(jpegEntries(w, large, false)...)
+				first := w.ifd(entry{tagCompression, typeShort, []uint32{1}})
+				w.setNext(first, last)
+				return w.ifd(append(jpegEntries(w, small, true),
+					entry{tagOrientation, typeShort, []uint32{6}},
+					entry{tagSubIFDs, typeLong, []uint32{first, 0xffffff}})...)
+			}, large},
+			{"IFD1", func(w *tiffWriter) uint32 {
+				ifd0 := w.ifd(entry{tagOrientation, typeShort, []uint32{6}})
+				w.setNext(ifd0, w.ifd(jpegEntries(w, large, false)...))
+				return ifd0
+			}, large},
+			{"loop", func(w *tiffWriter) uint32 {
+				ifd0 := w.ifd(append(jpegEntries(w, small, false),
+					entry{tagOrientation, typeShort, []uint32{6}})...)
+				ifd1 := w.ifd(entry{tagSubIFDs, typeLong, []uint32{ifd0}})
+				w.setNext(ifd0, ifd1)
+				w.setNext(ifd1, ifd0)
+				return ifd0
+			}, small},
+		}
+		for _, tt := range tests {
+			w := newTIFF(bo)
+			ifd0 := tt.build(w)
+			bo.PutUint32(w.buf[4:], ifd0)
+			jpg, o, err := ExtractJPEG(bytes.NewReader(w.buf), int64(len(w.buf)))
+			if err != nil {
+				t.Errorf("%v/%v: %v", bo, tt.name, err)
+				continue
+			}
+			if !bytes.Equal(jpg, tt.want) || o != 6 {
+				t.Errorf("%v/%v: got a %v byte JPEG and orientation %v, want %v bytes and 6", bo, tt.name, len(jpg), o, len(tt.want))
+			}
+		}
+	}
+}
+
+func TestExtractJPEGInvalid(t *testing.T) {
+	jpg := testJPEG(t, 16, 8)
+	bo := binary.LittleEndian
+	tests := []struct {
+		name  string
+		build func(w *tiffWriter) (ifd0 uint32)
+	}{
+		{"IFD0 out of range", func(w *tiffWriter) uint32 {
+			return 1 << 30
+		}},
+		{"too many entries", func(w *tiffWriter) uint32 {
+			ifd := w.ifd(jpegEntries(w, jpg, false)...)
+			bo.PutUint16(w.buf[ifd:], 0xffff)
+			return ifd
+		}},
+		{"JPEG out of range", func(w *tiffWriter) uint32 {
+			return w.ifd(entry{tagJPEGOffset, typeLong, []uint32{1 << 30}}, entry{tagJPEGLength, typeLong, []uint32{100}})
+		}},
+		{"JPEG length out of range", func(w *tiffWriter) uint32 {
+			e := jpegEntries(w, jpg, false)
+			e[1].vals[0] = 0xffffffff
+			return w.ifd(e...)
+		}},
+		{"values out of range", func(w *tiffWriter) uint32 {
+			ifd := w.ifd(append(jpegEntries(w, jpg, true)[:1],
+				entry{tagStripOffsets, typeLong, []uint32{0, 0}},
+				entry{tagStripByteCounts, typeLong, []uint32{0, 0}})...)
+			// Both out-of-line values.
+			bo.PutUint32(w.buf[ifd+2+12+8:], 1<<30)
+			bo.PutUint32(w.buf[ifd+2+24+8:], 1<<30)
+			return ifd
+		}},
+		{"sub IFDs out of range", func(w *tiffWriter) uint32 {
+			return w.ifd(entry{tagSubIFDs, typeLong, []uint32{1 << 30, 3, 0}})
+		}},
+		{"too many values", func(w *tiffWriter) uint32 {
+			ifd := w.ifd(entry{tagSubIFDs, typeLong, []uint32{0, 0}})
+			bo.PutUint32(w.buf[ifd+2+4:], 1<<30)
+			return ifd
+		}},
+		{"not a JPEG", func(w *tiffWriter) uint32 {
+			e := jpegEntries(w, jpg, false)
+			w.buf[e[0].vals[0]] = 0
+			return w.ifd(e...)
+		}},
+	}
+	for _, tt := range tests {
+		w := newTIFF(bo)
+		ifd0 := tt.build(w)
+		bo.PutUint32(w.buf[4:], ifd0)
+		if _, _, err := ExtractJPEG(bytes.NewReader(w.buf), int64(len(w.buf))); err == nil {
+			t.Errorf("%v: no error", tt.name)
+		}
+	}
+	for _, data := range []string{"", "II*", "II\x2b\x00\x08\x00\x00\x00", "PK\x03\x04\x00\x00\x00\x00"} {
+		if _, _, err := ExtractJPEG(bytes.NewReader([]byte(data)), int64(len(data))); !errors.Is(err, ErrNotTIFF) {
+			t.Errorf("%q: got error %v, want %v", data, err, ErrNotTIFF)
+		}
+	}
+
+	// Every truncation of a valid file, with the IFDs before the JPEGs and
+	// the size of the file either truncated too or not.
+	large := testJPEG(t, 64, 32)
+	w := newTIFF(binary.BigEndian)
+	ifd0 := w.ifd(
+		entry{tagOrientation, typeShort, []uint32{6}},
+		entry{tagSubIFDs, typeLong, []uint32{0}},
+		entry{tagJPEGOffset, typeLong, []uint32{0}},
+		entry{tagJPEGLength, typeLong, []uint32{uint32(len(jpg))}})
+	sub := w.ifd(
+		entry{tagCompression, typeShort, []uint32{compressionJPEG}},
+		entry{tagStripOffsets, typeLong, []uint32{0}},
+		entry{tagStripByteCounts, typeLong, []uint32{uint32(len(large))}})
+	w.bo.PutUint32(w.buf[4:], ifd0)
+	w.setValue(ifd0, 1, sub)
+	w.setValue(ifd0, 2, w.add(jpg))
+	w.setValue(sub, 1, w.add(large))
+	if res, _, err := ExtractJPEG(bytes.NewReader(w.buf), int64(len(w.buf))); err != nil || !bytes.Equal(res, large) {
+		t.Fatalf("got a %v byte JPEG and error %v, want %v bytes", len(res), err, len(large))
+	}
+	for n := range w.buf {
+		for _, size := range []int{n, len(w.buf)} {
+			res, _, err := ExtractJPEG(bytes.NewReader(w.buf[:n]), int64(size))
+			if err != nil {
+				continue
+			}
+			if !bytes.Equal(res, jpg) {
+				t.Errorf("truncated to %v bytes with size %v: got a %v byte JPEG, want the small one", n, size, len(res))
+			}
+		}
+	}
+}
+
+func TestOrient(t *testing.T) {
+	// A B C
+	// D E F
+	const w, h = 3, 2
+	src := image.NewGray(image.Rect(10, 20, 10+w, 20+h))
+	for i := range src.Pix {
+		src.Pix[i] = 'A' + uint8(i)
+	}
+	for o, want := range []string{
+		0: "ABC DEF",
+		1: "ABC DEF",
+		2: "CBA FED",
+		3: "FED CBA",
+		4: "DEF ABC",
+		5: "AD BE CF",
+		6: "DA EB FC",
+		7: "FC EB DA",
+		8: "CF BE AD",
+		9: "ABC DEF",
+	} {
+		img := Orient(src, o)
+		var got []byte
+		b := img.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			if y > b.Min.Y {
+				got = append(got, ' ')
+			}
+			for x := b.Min.X; x < b.Max.X; x++ {
+				got = append(got, color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y)
+			}
+		}
+		if string(got) != want {
+			t.Errorf("orientation %v: got %q, want %q", o, got, want)
+		}
+	}
+}
@@ -0,0 +1,416 @@
+package sign
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"hash"
+	"unicode/utf16"
+)
+
+var (
+	oidData                   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEncryptedData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+	oidKeyBag                 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 1}
+	oidShroudedKeyBag         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertBag                = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidX509Certificate        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidPBES2                  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2                 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidPBEWithSHAAnd3DES      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 3}
+	oidPBEWithSHAAnd40BitRC2  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 6}
+	oidHMACWithSHA1           = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256         = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHMACWithSHA384         = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 10}
+	oidHMACWithSHA512         = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+	oidAES128CBC              = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC              = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC              = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidDESEDE3CBC             = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+	oidSHA1                   = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256                 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384                 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512                 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+	errUnsupportedEncryption  = errors.New("unsupported encryption; export the file again with AES")
+	errRC2                    = errors.New("RC2 encryption is unsupported; export the file again with AES (e.g. without -legacy in OpenSSL)")
+	errIncorrectPassword      = errors.New("incorrect password")
+	errNoKeyOrCertificate     = errors.New("no private key or certificate found")
+	errKeyDoesNotMatchAnyCert = errors.New("no certificate matches the private key")
+)
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"tag:0,explicit,optional"`
+}
+
+type pfx struct {
+	Version  int
+	AuthSafe contentInfo
+	MacData  macData `asn1:"optional"`
+}
+
+type macData struct {
+	Mac struct {
+		Algorithm pkix.AlgorithmIdentifier
+		Digest    []byte
+	}
+	MacSalt    []byte
+	Iterations int `asn1:"optional,default:1"`
+}
+
+type encryptedData struct {
+	Version     int
+	ContentInfo encryptedContentInfo
+}
+
+type encryptedContentInfo struct {
+	ContentType      asn1.ObjectIdentifier
+	Algorithm        pkix.AlgorithmIdentifier
+	EncryptedContent []byte `asn1:"tag:0,optional"`
+}
+
+type safeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue `asn1:"tag:0,explicit"`
+	Attributes asn1.RawValue `asn1:"optional"`
+}
+
+type certBag struct {
+	ID   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Data      []byte
+}
+
+type pbes2Params struct {
+	KDF    pkix.AlgorithmIdentifier
+	Scheme pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt       []byte
+	Iterations int
+	KeyLength  int                      `asn1:"optional"`
+	PRF        pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+type pbeParams struct {
+	Salt       []byte
+	Iterations int
+}
+
+// Returns the private key and certificates of a PKCS#12 (.p12 or .pfx) file.
+// Keys and certificates encrypted with PBES2 (as by current versions of
+// OpenSSL and Windows) or with SHA-1 and 3DES are supported, but not those
+// encrypted with RC2.
+func parsePKCS12(data []byte, password string) (any, []*x509.Certificate, error) {
+	var p pfx
+	if rest, err := asn1.Unmarshal(data, &p); err != nil || len(rest) > 0 {
+		return nil, nil, errors.New("not a PKCS#12 file")
+	}
+	if !p.AuthSafe.ContentType.Equal(oidData) {
+		return nil, nil, errors.New("PKCS#12 files signed with a public key are unsupported")
+	}
+	var authSafe []byte
+	if _, err := asn1.Unmarshal(p.AuthSafe.Content.Bytes, &authSafe); err != nil {
+		return nil, nil, err
+	}
+	if p.MacData.Mac.Algorithm.Algorithm != nil {
+		if err := checkMAC(p.MacData, authSafe, password); err != nil {
+			return nil, nil, err
+		}
+	}
+	var infos []contentInfo
+	if _, err := asn1.Unmarshal(authSafe, &infos); err != nil {
+		return nil, nil, err
+	}
+	var key any
+	var certs []*x509.Certificate
+	for _, ci := range infos {
+		var contents []byte
+		switch {
+		case ci.ContentType.Equal(oidData):
+			if _, err := asn1.Unmarshal(ci.Content.Bytes, &contents); err != nil {
+				return nil, nil, err
+			}
+		case ci.ContentType.Equal(oidEncryptedData):
+			var ed encryptedData
+			if _, err := asn1.Unmarshal(ci.Content.Bytes, &ed); err != nil {
+				return nil, nil, err
+			}
+			var err error
+			contents, err = decrypt(ed.ContentInfo.Algorithm, ed.ContentInfo.EncryptedContent, password)
+			if err != nil {
+				return nil, nil, err
+			}
+		default:
+			return nil, nil, fmt.Errorf("unsupported PKCS#12 content %v", ci.ContentType)
+		}
+		var bags []safeBag
+		if _, err := asn1.Unmarshal(contents, &bags); err != nil {
+			return nil, nil, err
+		}
+		for _, bag := range bags {
+			switch {
+			case bag.ID.Equal(oidCertBag):
+				var cb certBag
+				if _, err := asn1.Unmarshal(bag.Value.Bytes, &cb); err != nil {
+					return nil, nil, err
+				}
+				if !cb.ID.Equal(oidX509Certificate) {
+					continue
+				}
+				cert, err := x509.ParseCertificate(cb.Data)
+				if err != nil {
+					return nil, nil, err
+				}
+				certs = append(certs, cert)
+			case bag.ID.Equal(oidKeyBag):
+				k, err := x509.ParsePKCS8PrivateKey(bag.Value.Bytes)
+				if err != nil {
+					return nil, nil, err
+				}
+				key = k
+			case bag.ID.Equal(oidShroudedKeyBag):
+				k, err := decryptPKCS8(bag.Value.Bytes, password)
+				if err != nil {
+					return nil, nil, err
+				}
+				key = k
+			}
+		}
+	}
+	if key == nil || len(certs) == 0 {
+		return nil, nil, errNoKeyOrCertificate
+	}
+	return key, certs, nil
+}
+
+// Returns the private key of DER encoded EncryptedPrivateKeyInfo.
+func decryptPKCS8(der []byte, password string) (any, error) {
+	var epki encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &epki); err != nil {
+		return nil, err
+	}
+	data, err := decrypt(epki.Algorithm, epki.Data, password)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(data)
+	if err != nil {
+		// Padding can be correct by chance with a wrong password.
+		return nil, errIncorrectPassword
+	}
+	return key, nil
+}
+
+// Limits the work a hostile file can cause. Files use some thousand
+// iterations (2048 by OpenSSL), rarely some hundred thousand.
+const maxIterations = 2_000_000
+
+func checkIterations(n int) error {
+	if n < 1 || n > maxIterations {
+		return fmt.Errorf("unsupported iteration count %v", n)
+	}
+	return nil
+}
+
+func checkMAC(md macData, content []byte, password string) error {
+	newHash, err := hashFunc(md.Mac.Algorithm.Algorithm)
+	if err != nil {
+		return err
+	}
+	if err := checkIterations(md.Iterations); err != nil {
+		return err
+	}
+	key := pkcs12KDF(newHash, bmpString(password), md.MacSalt, 3, md.Iterations, newHash().Size())
+	mac := hmac.New(newHash, key)
+	mac.Write(content)
+	if !hmac.Equal(mac.Sum(nil), md.Mac.Digest) {
+		return errIncorrectPassword
+	}
+	return nil
+}
+
+func hashFunc(oid asn1.ObjectIdentifier) (func() hash.Hash, error) {
+	switch {
+	case oid.Equal(oidSHA1), oid.Equal(oidHMACWithSHA1):
+		return sha1.New, nil
+	case oid.Equal(oidSHA256), oid.Equal(oidHMACWithSHA256):
+		return sha256.New, nil
+	case oid.Equal(oidSHA384), oid.Equal(oidHMACWithSHA384):
+		return sha512.New384, nil
+	case oid.Equal(oidSHA512), oid.Equal(oidHMACWithSHA512):
+		return sha512.New, nil
+	}
+	return nil, fmt.Errorf("unsupported hash algorithm %v", oid)
+}
+
+// Decrypts data encrypted with a password based algorithm.
+func decrypt(alg pkix.AlgorithmIdentifier, data []byte, password string) ([]byte, error) {
+	var block cipher.Block
+	var iv []byte
+	switch {
+	case alg.Algorithm.Equal(oidPBES2):
+		var params pbes2Params
+		if _, err := asn1.Unmarshal(alg.Parameters.FullBytes, &params); err != nil {
+			return nil, err
+		}
+		if !params.KDF.Algorithm.Equal(oidPBKDF2) {
+			return nil, errUnsupportedEncryption
+		}
+		var kdf pbkdf2Params
+		if _, err := asn1.Unmarshal(params.KDF.Parameters.FullBytes, &kdf); err != nil {
+			return nil, err
+		}
+		if err := checkIterations(kdf.Iterations); err != nil {
+			return nil, err
+		}
+		prf := sha1.New
+		if kdf.PRF.Algorithm != nil {
+			var err error
+			if prf, err = hashFunc(kdf.PRF.Algorithm); err != nil {
+				return nil, err
+			}
+		}
+		var keyLen int
+		var newCipher func([]byte) (cipher.Block, error)
+		switch s := params.Scheme.Algorithm; {
+		case s.Equal(oidAES128CBC):
+			keyLen, newCipher = 16, aes.NewCipher
+		case s.Equal(oidAES192CBC):
+			keyLen, newCipher = 24, aes.NewCipher
+		case s.Equal(oidAES256CBC):
+			keyLen, newCipher = 32, aes.NewCipher
+		case s.Equal(oidDESEDE3CBC):
+			keyLen, newCipher = 24, des.NewTripleDESCipher
+		default:
+			return nil, errUnsupportedEncryption
+		}
+		if _, err := asn1.Unmarshal(params.Scheme.Parameters.FullBytes, &iv); err != nil {
+			return nil, err
+		}
+		var err error
+		key := pbkdf2(prf, []byte(password), kdf.Salt, kdf.Iterations, keyLen)
+		if block, err = newCipher(key); err != nil {
+			return nil, err
+		}
+	case alg.Algorithm.Equal(oidPBEWithSHAAnd3DES):
+		var params pbeParams
+		if _, err := asn1.Unmarshal(alg.Parameters.FullBytes, &params); err != nil {
+			return nil, err
+		}
+		if err := checkIterations(params.Iterations); err != nil {
+			return nil, err
+		}
+		pw := bmpString(password)
+		key := pkcs12KDF(sha1.New, pw, params.Salt, 1, params.Iterations, 24)
+		iv = pkcs12KDF(sha1.New, pw, params.Salt, 2, params.Iterations, 8)
+		var err error
+		if block, err = des.NewTripleDESCipher(key); err != nil {
+			return nil, err
+		}
+	case alg.Algorithm.Equal(oidPBEWithSHAAnd40BitRC2):
+		return nil, errRC2
+	default:
+		return nil, errUnsupportedEncryption
+	}
+	if len(iv) != block.BlockSize() || len(data) == 0 || len(data)%block.BlockSize() != 0 {
+		return nil, errors.New("invalid encrypted data")
+	}
+	res := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(res, data)
+	n := int(res[len(res)-1])
+	if n == 0 || n > block.BlockSize() || !bytes.Equal(res[len(res)-n:], bytes.Repeat([]byte{byte(n)}, n)) {
+		return nil, errIncorrectPassword
+	}
+	return res[:len(res)-n], nil
+}
+
+// Derives a key as in PKCS#5 (RFC 8018).
+func pbkdf2(prf func() hash.Hash, password, salt []byte, iterations, keyLen int) []byte {
+	mac := hmac.New(prf, password)
+	var res []byte
+	for block := uint32(1); len(res) < keyLen; block++ {
+		mac.Reset()
+		mac.Write(salt)
+		mac.Write([]byte{byte(block >> 24), byte(block >> 16), byte(block >> 8), byte(block)})
+		u := mac.Sum(nil)
+		t := append([]byte{}, u...)
+		for i := 1; i < iterations; i++ {
+			mac.Reset()
+			mac.Write(u)
+			u = mac.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		res = append(res, t...)
+	}
+	return res[:keyLen]
+}
+
+// Derives key material with the given purpose (1 for keys, 2 for IVs and 3
+// for MAC keys) as in PKCS#12 (RFC 7292, appendix B).
+func pkcs12KDF(newHash func() hash.Hash, password, salt []byte, id byte, iterations, n int) []byte {
+	h := newHash()
+	v := h.BlockSize()
+	fill := func(s []byte) []byte {
+		if len(s) == 0 {
+			return nil
+		}
+		res := make([]byte, (len(s)+v-1)/v*v)
+		for i := range res {
+			res[i] = s[i%len(s)]
+		}
+		return res
+	}
+	d := bytes.Repeat([]byte{id}, v)
+	in := append(fill(salt), fill(password)...)
+	var res []byte
+	for len(res) < n {
+		h.Reset()
+		h.Write(d)
+		h.Write(in)
+		a := h.Sum(nil)
+		for i := 1; i < iterations; i++ {
+			h.Reset()
+			h.Write(a)
+			a = h.Sum(a[:0])
+		}
+		res = append(res, a...)
+		// Adds a repeated and 1 to each block of the input.
+		b := fill(a)[:v]
+		for j := 0; j < len(in); j += v {
+			carry := 1
+			for k := v - 1; k >= 0; k-- {
+				sum := int(in[j+k]) + int(b[k]) + carry
+				in[j+k] = byte(sum)
+				carry = sum >> 8
+			}
+		}
+	}
+	return res[:n]
+}
+
+// Returns s as null terminated UTF-16BE, as passwords are used by PKCS#12.
+func bmpString(s string) []byte {
+	var res []byte
+	for _, c := range utf16.Encode([]rune(s)) {
+		res = append(res, byte(c>>8), byte(c))
+	}
+	return append(res, 0, 0)
+}
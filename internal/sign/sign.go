@@ -0,0 +1,238 @@
+// Package sign creates and verifies the CMS signatures of signed PDFs, as
+// in PAdES baseline B (subfilter ETSI.CAdES.detached).
+package sign
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+)
+
+var (
+	oidSignedData         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidContentType        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidMessageDigest      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSigningCertificate = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 47}
+	oidRSAEncryption      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidSHA256WithRSA      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	oidECDSAWithSHA256    = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+)
+
+// A private key with its certificate.
+type Signer struct {
+	Key crypto.Signer
+	// The certificate of Key first, then others of its chain, if known.
+	Chain []*x509.Certificate
+}
+
+// Loads a signer from a PKCS#12 (.p12 or .pfx) file, or from a PEM file
+// with the private key and, unless certFile is given, the certificates.
+// password decrypts PKCS#12 files and encrypted PEM keys.
+func Load(keyFile, certFile, password string) (*Signer, error) {
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	var key any
+	var certs []*x509.Certificate
+	if bytes.Contains(data, []byte("-----BEGIN")) {
+		key, certs, err = parsePEM(data, password)
+	} else {
+		key, certs, err = parsePKCS12(data, password)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if certFile != "" {
+		data, err := os.ReadFile(certFile)
+		if err != nil {
+			return nil, err
+		}
+		if _, certs, err = parsePEM(data, ""); err != nil {
+			return nil, err
+		}
+	}
+	if key == nil || len(certs) == 0 {
+		return nil, errNoKeyOrCertificate
+	}
+	switch key.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey:
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, only RSA and ECDSA keys are supported", key)
+	}
+	s := &Signer{Key: key.(crypto.Signer)}
+	// The certificate of the key goes first.
+	pub := s.Key.Public().(interface{ Equal(crypto.PublicKey) bool })
+	i := slices.IndexFunc(certs, func(c *x509.Certificate) bool { return pub.Equal(c.PublicKey) })
+	if i == -1 {
+		return nil, errKeyDoesNotMatchAnyCert
+	}
+	cert := certs[i]
+	s.Chain = append([]*x509.Certificate{cert}, slices.Delete(certs, i, i+1)...)
+	return s, nil
+}
+
+// Returns the private key and certificates in PEM data.
+func parsePEM(data []byte, password string) (any, []*x509.Certificate, error) {
+	var key any
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		var err error
+		switch block.Type {
+		case "CERTIFICATE":
+			var cert *x509.Certificate
+			if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+				certs = append(certs, cert)
+			}
+		case "PRIVATE KEY":
+			key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		case "RSA PRIVATE KEY", "EC PRIVATE KEY":
+			if _, ok := block.Headers["DEK-Info"]; ok {
+				return nil, nil, errors.New("keys in legacy encrypted PEM are unsupported; convert the key to PKCS#8")
+			}
+			if block.Type == "RSA PRIVATE KEY" {
+				key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+			} else {
+				key, err = x509.ParseECPrivateKey(block.Bytes)
+			}
+		case "ENCRYPTED PRIVATE KEY":
+			key, err = decryptPKCS8(block.Bytes, password)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return key, certs, nil
+}
+
+// Returns the most bytes Sign may return.
+func (s *Signer) MaxSize() int {
+	n := 4096
+	for _, c := range s.Chain {
+		n += len(c.Raw)
+	}
+	return n
+}
+
+type issuerAndSerial struct {
+	Issuer asn1.RawValue
+	Serial *big.Int
+}
+
+type attribute struct {
+	Type asn1.ObjectIdentifier
+	// SET OF AttributeValue.
+	Values asn1.RawValue
+}
+
+type signerInfo struct {
+	Version            int
+	SID                issuerAndSerial
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	EncapContentInfo contentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	SignerInfos      asn1.RawValue
+}
+
+type signingCertificateV2 struct {
+	Certs []essCertIDv2
+}
+
+// With the default hash algorithm, SHA-256.
+type essCertIDv2 struct {
+	CertHash []byte
+}
+
+// Returns a set of DER encoded elements, which are sorted as required.
+func set(class, tag int, elems ...[]byte) asn1.RawValue {
+	elems = slices.Clone(elems)
+	slices.SortFunc(elems, bytes.Compare)
+	return asn1.RawValue{Class: class, Tag: tag, IsCompound: true, Bytes: bytes.Join(elems, nil)}
+}
+
+func mustMarshal(v any) []byte {
+	b, err := asn1.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// Returns a detached CMS signature (SignedData) of data. The signing time
+// isn't included, as PAdES has it in the signature dictionary.
+func (s *Signer) Sign(data []byte) ([]byte, error) {
+	cert := s.Chain[0]
+	digest := sha256.Sum256(data)
+	certHash := sha256.Sum256(cert.Raw)
+	attr := func(oid asn1.ObjectIdentifier, value any) []byte {
+		return mustMarshal(attribute{
+			Type:   oid,
+			Values: set(asn1.ClassUniversal, asn1.TagSet, mustMarshal(value)),
+		})
+	}
+	attrs := set(asn1.ClassContextSpecific, 0,
+		attr(oidContentType, oidData),
+		attr(oidMessageDigest, digest[:]),
+		attr(oidSigningCertificate, signingCertificateV2{Certs: []essCertIDv2{{CertHash: certHash[:]}}}),
+	)
+	// The signature covers the attributes tagged as a set.
+	toSign := set(asn1.ClassUniversal, asn1.TagSet, attrs.Bytes)
+	attrsDigest := sha256.Sum256(mustMarshal(toSign))
+	sig, err := s.Key.Sign(rand.Reader, attrsDigest[:], crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	sigAlg := pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA256}
+	if _, ok := s.Key.(*rsa.PrivateKey); ok {
+		sigAlg = pkix.AlgorithmIdentifier{Algorithm: oidRSAEncryption, Parameters: asn1.NullRawValue}
+	}
+	digestAlg := pkix.AlgorithmIdentifier{Algorithm: oidSHA256}
+	si := signerInfo{
+		Version:            1,
+		SID:                issuerAndSerial{Issuer: asn1.RawValue{FullBytes: cert.RawIssuer}, Serial: cert.SerialNumber},
+		DigestAlgorithm:    digestAlg,
+		SignedAttrs:        attrs,
+		SignatureAlgorithm: sigAlg,
+		Signature:          sig,
+	}
+	var certs [][]byte
+	for _, c := range s.Chain {
+		certs = append(certs, c.Raw)
+	}
+	sd := signedData{
+		Version:          1,
+		DigestAlgorithms: set(asn1.ClassUniversal, asn1.TagSet, mustMarshal(digestAlg)),
+		EncapContentInfo: contentInfo{ContentType: oidData},
+		Certificates:     set(asn1.ClassContextSpecific, 0, certs...),
+		SignerInfos:      set(asn1.ClassUniversal, asn1.TagSet, mustMarshal(si)),
+	}
+	return asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: mustMarshal(sd)},
+	})
+}
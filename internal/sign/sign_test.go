@@ -0,0 +1,139 @@
+package sign
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// The files in testdata were made with OpenSSL 3:
+//
+//	openssl pkcs12 -export -inkey rsa.key -in rsa-cert.pem -certfile ca.pem -passout pass:test -out rsa-aes.p12
+//	openssl pkcs12 -export -legacy -certpbe PBE-SHA1-3DES -keypbe PBE-SHA1-3DES -macalg sha1 ... -out rsa-3des.p12
+//	openssl pkcs12 -export -legacy ... -out rsa-rc2.p12
+//	openssl pkcs8 -topk8 -v2 aes-256-cbc -in rsa.key -passout pass:test -out rsa-key-encrypted.pem
+//
+// ec.pem holds the key and the certificate of the CA (ca.pem), which issued
+// rsa-cert.pem.
+const testPassword = "test"
+
+func TestPBKDF2(t *testing.T) {
+	// From RFC 6070.
+	tests := []struct {
+		password, salt string
+		iterations     int
+		want           string
+	}{
+		{"password", "salt", 1, "0c60c80f961f0e71f3a9b524af6012062fe037a6"},
+		{"password", "salt", 2, "ea6c014dc72d6f8ccd1ed92ace1d41f0d8de8957"},
+		{"password", "salt", 4096, "4b007901b765489abead49d926f721d065a429c1"},
+		{"passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt", 4096, "3d2eec4fe41c849b80c8d83662c0e44a8b291a964cf2f07038"},
+		{"pass\x00word", "sa\x00lt", 4096, "56fa6aa75548099dcc37d7f03425e0c3"},
+	}
+	for _, tt := range tests {
+		want, _ := hex.DecodeString(tt.want)
+		got := pbkdf2(sha1.New, []byte(tt.password), []byte(tt.salt), tt.iterations, len(want))
+		if !bytes.Equal(got, want) {
+			t.Errorf("pbkdf2(%q, %q, %v) = %x, want %x", tt.password, tt.salt, tt.iterations, got, want)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	testdata := func(name string) string {
+		if name == "" {
+			return ""
+		}
+		return filepath.Join("testdata", name)
+	}
+	tests := []struct {
+		key, cert, password string
+		wantErr             error
+		// Common name of the certificate of the key.
+		wantCN    string
+		wantChain int
+		wantEC    bool
+	}{
+		{key: "rsa-aes.p12", password: testPassword, wantCN: "pic4pdf Test Signer", wantChain: 2},
+		{key: "rsa-3des.p12", password: testPassword, wantCN: "pic4pdf Test Signer", wantChain: 2},
+		{key: "rsa-key-encrypted.pem", cert: "rsa-cert.pem", password: testPassword, wantCN: "pic4pdf Test Signer", wantChain: 1},
+		{key: "ec.pem", wantCN: "pic4pdf Test CA", wantChain: 1, wantEC: true},
+		{key: "rsa-aes.p12", password: "wrong", wantErr: errIncorrectPassword},
+		{key: "rsa-3des.p12", password: "wrong", wantErr: errIncorrectPassword},
+		{key: "rsa-key-encrypted.pem", cert: "rsa-cert.pem", password: "wrong", wantErr: errIncorrectPassword},
+		{key: "rsa-rc2.p12", password: testPassword, wantErr: errRC2},
+		{key: "ec.pem", cert: "rsa-cert.pem", wantErr: errKeyDoesNotMatchAnyCert},
+		{key: "rsa-key-encrypted.pem", password: testPassword, wantErr: errNoKeyOrCertificate},
+	}
+	for _, tt := range tests {
+		name := tt.key + "+" + tt.cert + "/" + tt.password
+		s, err := Load(testdata(tt.key), testdata(tt.cert), tt.password)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("%v: got error %v, want %v", name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", name, err)
+			continue
+		}
+		if cn := s.Chain[0].Subject.CommonName; cn != tt.wantCN || len(s.Chain) != tt.wantChain {
+			t.Errorf("%v: got %q and %v certificates, want %q and %v", name, cn, len(s.Chain), tt.wantCN, tt.wantChain)
+		}
+		switch s.Key.(type) {
+		case *rsa.PrivateKey:
+			if tt.wantEC {
+				t.Errorf("%v: got an RSA key, want ECDSA", name)
+			}
+		case *ecdsa.PrivateKey:
+			if !tt.wantEC {
+				t.Errorf("%v: got an ECDSA key, want RSA", name)
+			}
+		}
+	}
+}
+
+func TestIterationLimit(t *testing.T) {
+	md := macData{MacSalt: []byte("salt"), Iterations: 1 << 40}
+	md.Mac.Algorithm.Algorithm = oidSHA1
+	if err := checkMAC(md, nil, testPassword); err == nil || errors.Is(err, errIncorrectPassword) {
+		t.Errorf("checkMAC with %v iterations: got error %v", md.Iterations, err)
+	}
+	params, err := asn1.Marshal(pbeParams{Salt: []byte("salt"), Iterations: 1 << 40})
+	if err != nil {
+		t.Fatal(err)
+	}
+	alg := pkix.AlgorithmIdentifier{Algorithm: oidPBEWithSHAAnd3DES, Parameters: asn1.RawValue{FullBytes: params}}
+	if _, err := decrypt(alg, make([]byte, 16), testPassword); err == nil || errors.Is(err, errIncorrectPassword) {
+		t.Errorf("decrypt with %v iterations: got error %v", 1<<40, err)
+	}
+}
+
+func TestVerifyPDFByteRange(t *testing.T) {
+	const maxInt = "9223372036854775807"
+	// The contents are at offset 9 to 13.
+	for _, byteRange := range []string{
+		"[0 9 " + maxInt + " " + maxInt + "]",
+		"[0 9 13 " + maxInt + "]",
+		"[0 1 " + maxInt + " " + maxInt + "]",
+		"[0 " + maxInt + " 3 4]",
+		"[0 99999999999999999999999 3 4]",
+		"[0 5 5 0]",
+		"[0 5 3 0]",
+		"[1 2 3 4]",
+		"[0 1 2 0]",
+	} {
+		data := []byte("%PDF-1.7\n<00> /ByteRange " + byteRange + "\n%%EOF\n")
+		if _, err := VerifyPDF(data); err == nil {
+			t.Errorf("byte range %v: no error", byteRange)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package sign
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// A valid signature of a PDF.
+type Result struct {
+	// The certificate of the signer, followed by the rest of the chain
+	// included in the signature.
+	Chain []*x509.Certificate
+	// From the signature dictionary. Zero if unknown, as in encrypted files.
+	Time time.Time
+	// Whether the signature covers the whole file, i.e. the file wasn't
+	// changed after signing.
+	WholeFile bool
+	// Why the certificate isn't trusted by the system, or nil if it is.
+	TrustErr error
+}
+
+var (
+	byteRangeRe = regexp.MustCompile(`/ByteRange\s*\[\s*(\d+)\s+(\d+)\s+(\d+)\s+(\d+)\s*\]`)
+	timeRe      = regexp.MustCompile(`^\s*/M\s*\(D:(\d{14})Z\)`)
+)
+
+// Checks the signatures of a PDF written by pic4pdf. Returns an error if it
+// has none or one of them is invalid.
+func VerifyPDF(data []byte) ([]Result, error) {
+	var res []Result
+	for _, m := range byteRangeRe.FindAllSubmatchIndex(data, -1) {
+		errByteRange := errors.New("invalid byte range of signature")
+		var r [4]int
+		for i := range r {
+			n, err := strconv.Atoi(string(data[m[2+2*i]:m[3+2*i]]))
+			// Bounded, so that sums can't overflow.
+			if err != nil || n > len(data) {
+				return nil, errByteRange
+			}
+			r[i] = n
+		}
+		start, end := r[1], r[2]
+		if r[0] != 0 || r[1] >= r[2] || end+r[3] > len(data) || data[start] != '<' || data[end-1] != '>' {
+			return nil, errByteRange
+		}
+		der, err := hex.DecodeString(string(data[start+1 : end-1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid signature contents: %w", err)
+		}
+		signed := append(data[:start:start], data[end:end+r[3]]...)
+		chain, err := verifyCMS(der, signed)
+		if err != nil {
+			return nil, err
+		}
+		result := Result{Chain: chain, WholeFile: end+r[3] == len(data)}
+		// Keys are written sorted, so the signing time follows the contents
+		// (and the filter).
+		if i := bytes.Index(data[end:], []byte("/M")); i != -1 {
+			if tm := timeRe.FindSubmatch(data[end+i:]); tm != nil {
+				result.Time, _ = time.Parse("20060102150405", string(tm[1]))
+			}
+		}
+		intermediates := x509.NewCertPool()
+		for _, c := range chain[1:] {
+			intermediates.AddCert(c)
+		}
+		_, result.TrustErr = chain[0].Verify(x509.VerifyOptions{
+			Intermediates: intermediates,
+			CurrentTime:   result.Time,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		})
+		res = append(res, result)
+	}
+	if len(res) == 0 {
+		return nil, errors.New("the PDF isn't signed")
+	}
+	return res, nil
+}
+
+// Verifies a detached CMS signature of content made by Sign, returning the
+// certificate chain of the signer.
+func verifyCMS(der, content []byte) ([]*x509.Certificate, error) {
+	invalid := func(err error) error {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+	var ci contentInfo
+	// Trailing zeros fill the space reserved for the signature.
+	if _, err := asn1.Unmarshal(der, &ci); err != nil {
+		return nil, invalid(err)
+	}
+	if !ci.ContentType.Equal(oidSignedData) {
+		return nil, invalid(errors.New("not signed data"))
+	}
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, invalid(err)
+	}
+	certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	if err != nil {
+		return nil, invalid(err)
+	}
+	var si signerInfo
+	if _, err := asn1.Unmarshal(sd.SignerInfos.Bytes, &si); err != nil {
+		return nil, invalid(err)
+	}
+	var cert *x509.Certificate
+	for i, c := range certs {
+		if bytes.Equal(c.RawIssuer, si.SID.Issuer.FullBytes) && c.SerialNumber.Cmp(si.SID.Serial) == 0 {
+			cert = c
+			certs = append([]*x509.Certificate{c}, append(certs[:i:i], certs[i+1:]...)...)
+			break
+		}
+	}
+	if cert == nil {
+		return nil, invalid(errors.New("certificate of the signer is missing"))
+	}
+	newHash, err := hashFunc(si.DigestAlgorithm.Algorithm)
+	if err != nil {
+		return nil, invalid(err)
+	}
+	h := newHash()
+	h.Write(content)
+	digest := h.Sum(nil)
+	var digestOK bool
+	for rest := si.SignedAttrs.Bytes; len(rest) > 0; {
+		var a attribute
+		if rest, err = asn1.Unmarshal(rest, &a); err != nil {
+			return nil, invalid(err)
+		}
+		switch {
+		case a.Type.Equal(oidMessageDigest):
+			var d []byte
+			if _, err := asn1.Unmarshal(a.Values.Bytes, &d); err != nil {
+				return nil, invalid(err)
+			}
+			if !bytes.Equal(d, digest) {
+				return nil, errors.New("the signed content was changed")
+			}
+			digestOK = true
+		case a.Type.Equal(oidSigningCertificate):
+			var sc signingCertificateV2
+			if _, err := asn1.Unmarshal(a.Values.Bytes, &sc); err != nil {
+				return nil, invalid(err)
+			}
+			h := newHash()
+			h.Write(cert.Raw)
+			if len(sc.Certs) == 0 || !bytes.Equal(sc.Certs[0].CertHash, h.Sum(nil)) {
+				return nil, invalid(errors.New("signing certificate doesn't match"))
+			}
+		}
+	}
+	if !digestOK {
+		return nil, invalid(errors.New("message digest is missing"))
+	}
+	var alg x509.SignatureAlgorithm
+	switch sa, d := si.SignatureAlgorithm.Algorithm, si.DigestAlgorithm.Algorithm; {
+	case (sa.Equal(oidRSAEncryption) || sa.Equal(oidSHA256WithRSA)) && d.Equal(oidSHA256):
+		alg = x509.SHA256WithRSA
+	case sa.Equal(oidECDSAWithSHA256) && d.Equal(oidSHA256):
+		alg = x509.ECDSAWithSHA256
+	default:
+		return nil, invalid(fmt.Errorf("unsupported signature algorithm %v", sa))
+	}
+	attrs := mustMarshal(set(asn1.ClassUniversal, asn1.TagSet, si.SignedAttrs.Bytes))
+	if err := cert.CheckSignature(alg, attrs, si.Signature); err != nil {
+		return nil, invalid(err)
+	}
+	return certs, nil
+}
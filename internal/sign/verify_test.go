@@ -0,0 +1,73 @@
+package sign_test
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	p4p "github.com/pic4pdf/lib-p4p"
+
+	"github.com/pic4pdf/pic4pdf/internal/document"
+	"github.com/pic4pdf/pic4pdf/internal/export"
+	"github.com/pic4pdf/pic4pdf/internal/pdf"
+	"github.com/pic4pdf/pic4pdf/internal/sign"
+)
+
+func TestVerifyPDF(t *testing.T) {
+	pages := []document.Page{
+		{Kind: document.TextPage, Text: document.Text{Heading: "Signed", Body: "Signed text"}},
+		{Kind: document.BlankPage},
+	}
+	for _, tt := range []struct {
+		name, key string
+		enc       *pdf.Encryption
+	}{
+		{"RSA", "rsa-aes.p12", nil},
+		{"ECDSA", "ec.pem", nil},
+		{"encrypted", "rsa-aes.p12", &pdf.Encryption{Permissions: pdf.PermitAll}},
+	} {
+		signer, err := sign.Load(filepath.Join("testdata", tt.key), "", "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		err = export.Write(&b, pages, export.Options{
+			PageSize:   p4p.A4(),
+			Margin:     50,
+			Encryption: tt.enc,
+			Signature:  &export.Signature{Signer: signer, StampPage: -1},
+		})
+		if err != nil {
+			t.Fatalf("%v: %v", tt.name, err)
+		}
+		data := b.Bytes()
+		res, err := sign.VerifyPDF(data)
+		if err != nil {
+			t.Fatalf("%v: %v", tt.name, err)
+		}
+		if len(res) != 1 || !res[0].WholeFile || !res[0].Chain[0].Equal(signer.Chain[0]) {
+			t.Fatalf("%v: got %+v, want one signature of the whole file by the signer", tt.name, res)
+		}
+		// The test CA isn't trusted.
+		if res[0].TrustErr == nil {
+			t.Errorf("%v: certificate is trusted", tt.name)
+		}
+		if tt.enc == nil && time.Since(res[0].Time) > time.Minute {
+			t.Errorf("%v: signing time is %v, want now", tt.name, res[0].Time)
+		}
+
+		appended := append(bytes.Clone(data), "% more\n"...)
+		if res, err := sign.VerifyPDF(appended); err != nil || res[0].WholeFile {
+			t.Errorf("%v: with appended data: got %+v, %v; want a signature of part of the file", tt.name, res, err)
+		}
+		// In the header, a page and the trailer, all of which are signed.
+		for _, i := range []int{len("%PDF-1."), bytes.Index(data, []byte("/MediaBox")) + 2, len(data) - 10} {
+			changed := bytes.Clone(data)
+			changed[i] ^= 1
+			if _, err := sign.VerifyPDF(changed); err == nil {
+				t.Errorf("%v: byte %v changed, but the signature is valid", tt.name, i)
+			}
+		}
+	}
+}
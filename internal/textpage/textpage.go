@@ -0,0 +1,234 @@
+// Package textpage lays out text pages, so that the preview and the export
+// place text identically.
+//
+// Text is set in the Go fonts, which are embedded in exported PDFs.
+package textpage
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+
+	"github.com/pic4pdf/pic4pdf/internal/document"
+)
+
+// TrueType data of the fonts used.
+var (
+	RegularTTF = goregular.TTF
+	BoldTTF    = gobold.TTF
+)
+
+// Used if document.Text.FontSize is not set.
+const DefaultFontSize = 14
+
+// Line height relative to the font size.
+const lineSpacing = 1.25
+
+// A line of text placed on the page.
+type Line struct {
+	Text string
+	// Start of the baseline in points from the top left of the page.
+	X, Y float64
+	// Font size in points.
+	Size float64
+	Bold bool
+}
+
+type faceKey struct {
+	size float64
+	bold bool
+}
+
+var (
+	// Guards everything below; faces are not safe for concurrent use.
+	mu      sync.Mutex
+	regular *opentype.Font
+	bold    *opentype.Font
+	faces   = make(map[faceKey]font.Face)
+)
+
+// Returns the regular or bold font. Requires mu to be locked!
+func parsed(isBold bool) *opentype.Font {
+	if regular == nil {
+		var err error
+		if regular, err = opentype.Parse(RegularTTF); err != nil {
+			panic(err)
+		}
+		if bold, err = opentype.Parse(BoldTTF); err != nil {
+			panic(err)
+		}
+	}
+	if isBold {
+		return bold
+	}
+	return regular
+}
+
+// Returns the face for the given size in points, where one pixel is one
+// point. Requires mu to be locked!
+func face(size float64, isBold bool) font.Face {
+	key := faceKey{size: size, bold: isBold}
+	if f, ok := faces[key]; ok {
+		return f
+	}
+	f, err := opentype.NewFace(parsed(isBold), &opentype.FaceOptions{
+		Size:    size,
+		DPI:     72,
+		Hinting: font.HintingNone,
+	})
+	if err != nil {
+		panic(err)
+	}
+	faces[key] = f
+	return f
+}
+
+func toFloat(v fixed.Int26_6) float64 {
+	return float64(v) / 64
+}
+
+// Breaks a paragraph into lines no wider than width. Words wider than width
+// are broken between characters.
+func wrap(f font.Face, par string, width float64) []string {
+	words := strings.Fields(par)
+	if len(words) == 0 {
+		return []string{""}
+	}
+	var lines []string
+	line := ""
+	for _, word := range words {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if toFloat(font.MeasureString(f, candidate)) <= width {
+			line = candidate
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+		line = word
+		for toFloat(font.MeasureString(f, line)) > width {
+			// Break the word at the last character which fits, but
+			// after at least one.
+			runes := []rune(line)
+			n := 1
+			for n < len(runes) && toFloat(font.MeasureString(f, string(runes[:n+1]))) <= width {
+				n++
+			}
+			if n == len(runes) {
+				break
+			}
+			lines = append(lines, string(runes[:n]))
+			line = string(runes[n:])
+		}
+	}
+	return append(lines, line)
+}
+
+// Lays out t on a w by h point page, starting margin points from the top
+// and sides. fits is false if the text reaches into the bottom margin, or
+// even continues below the page.
+func Layout(t document.Text, w, h, margin float64) (lines []Line, fits bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	size := t.FontSize
+	if size <= 0 {
+		size = DefaultFontSize
+	}
+	width := math.Max(w-2*margin, size)
+	var res []Line
+	y := margin
+	bottom := 0.0
+	add := func(text string, size float64, isBold bool) {
+		f := face(size, isBold)
+		for _, par := range strings.Split(text, "\n") {
+			for _, s := range wrap(f, par, width) {
+				x := margin
+				lineW := toFloat(font.MeasureString(f, s))
+				switch t.Align {
+				case document.AlignCenter:
+					x += (width - lineW) / 2
+				case document.AlignRight:
+					x += width - lineW
+				}
+				res = append(res, Line{
+					Text: s,
+					X:    x,
+					Y:    y + toFloat(f.Metrics().Ascent),
+					Size: size,
+					Bold: isBold,
+				})
+				bottom = y + toFloat(f.Metrics().Height)
+				y += size * lineSpacing
+			}
+		}
+	}
+	if t.Heading != "" {
+		add(t.Heading, 2*size, true)
+		y += size / 2
+	}
+	if t.Body != "" {
+		add(t.Body, size, false)
+	}
+	return res, bottom <= h-margin
+}
+
+// Returns the characters of t which the fonts lack, in the order they are
+// first used. They would be shown as boxes.
+func Missing(t document.Text) []rune {
+	mu.Lock()
+	defer mu.Unlock()
+	var buf sfnt.Buffer
+	seen := make(map[rune]bool)
+	var res []rune
+	check := func(text string, isBold bool) {
+		f := parsed(isBold)
+		for _, r := range text {
+			if unicode.IsSpace(r) || seen[r] {
+				continue
+			}
+			seen[r] = true
+			if gi, err := f.GlyphIndex(&buf, r); err != nil || gi == 0 {
+				res = append(res, r)
+			}
+		}
+	}
+	check(t.Heading, true)
+	check(t.Body, false)
+	return res
+}
+
+// Draws lines on an image of a w by h point page, with scale pixels per
+// point.
+func Render(lines []Line, w, h, scale float64, bg color.Color) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, int(math.Ceil(w*scale)), int(math.Ceil(h*scale))))
+	draw.Draw(img, img.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+	mu.Lock()
+	defer mu.Unlock()
+	for _, l := range lines {
+		d := font.Drawer{
+			Dst:  img,
+			Src:  image.Black,
+			Face: face(l.Size*scale, l.Bold),
+			Dot: fixed.Point26_6{
+				X: fixed.Int26_6(l.X * scale * 64),
+				Y: fixed.Int26_6(l.Y * scale * 64),
+			},
+		}
+		d.DrawString(l.Text)
+	}
+	return img
+}
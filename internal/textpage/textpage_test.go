@@ -0,0 +1,121 @@
+package textpage
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/font"
+
+	"github.com/pic4pdf/pic4pdf/internal/document"
+)
+
+// Returns the width of s and the ascent and height of its font.
+func measure(s string, size float64, isBold bool) (w, ascent, height float64) {
+	mu.Lock()
+	defer mu.Unlock()
+	f := face(size, isBold)
+	return toFloat(font.MeasureString(f, s)), toFloat(f.Metrics().Ascent), toFloat(f.Metrics().Height)
+}
+
+func TestWrap(t *testing.T) {
+	mu.Lock()
+	f := face(10, false)
+	mu.Unlock()
+	width := toFloat(font.MeasureString(f, "mmmmm"))
+	tests := []struct {
+		par   string
+		width float64
+		want  []string
+	}{
+		{"", width, []string{""}},
+		{"  ", width, []string{""}},
+		{"mm mm", width, []string{"mm mm"}},
+		{"mm  mm mm", width, []string{"mm mm", "mm"}},
+		{"mmmmm", width, []string{"mmmmm"}},
+		// Words wider than the line are split.
+		{"mmmmmmmmmmmm", width, []string{"mmmmm", "mmmmm", "mm"}},
+		{"mm mmmmmmm mm", width, []string{"mm", "mmmmm", "mm mm"}},
+		// At least one character per line.
+		{"mmm", 1, []string{"m", "m", "m"}},
+	}
+	for _, tt := range tests {
+		mu.Lock()
+		got := wrap(f, tt.par, tt.width)
+		mu.Unlock()
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("wrap(%q, %v) = %q, want %q", tt.par, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestLayout(t *testing.T) {
+	const w, h, margin = 400, 600, 50
+	x := func(align document.Align) float64 {
+		lines, _ := Layout(document.Text{Body: "Hello", FontSize: 10, Align: align}, w, h, margin)
+		if len(lines) != 1 {
+			t.Fatalf("%v lines, want 1", len(lines))
+		}
+		return lines[0].X
+	}
+	lineW, _, _ := measure("Hello", 10, false)
+	if left, center, right := x(document.AlignLeft), x(document.AlignCenter), x(document.AlignRight); left != margin ||
+		math.Abs(right-(w-margin-lineW)) > 1e-9 || math.Abs(center-(left+right)/2) > 1e-9 {
+		t.Errorf("line at %v, %v and %v when aligned left, center and right; want %v, %v and %v",
+			left, center, right, margin, (margin+w-margin-lineW)/2, w-margin-lineW)
+	}
+
+	lines, fits := Layout(document.Text{Heading: "Title", Body: "One\n\nTwo", FontSize: 10}, w, h, margin)
+	if len(lines) != 4 || !fits {
+		t.Fatalf("got %+v, fits %v; want 4 lines which fit", lines, fits)
+	}
+	_, headingAscent, _ := measure("", 20, true)
+	_, ascent, height := measure("", 10, false)
+	// The heading is twice the size, and half a line apart from the body.
+	want := []Line{
+		{"Title", margin, margin + headingAscent, 20, true},
+		{"One", margin, margin + 20*lineSpacing + 5 + ascent, 10, false},
+		{"", margin, margin + 20*lineSpacing + 5 + 10*lineSpacing + ascent, 10, false},
+		{"Two", margin, margin + 20*lineSpacing + 5 + 20*lineSpacing + ascent, 10, false},
+	}
+	for i := range want {
+		if got := lines[i]; got.Text != want[i].Text || math.Abs(got.Y-want[i].Y) > 1e-9 || got.Size != want[i].Size || got.Bold != want[i].Bold {
+			t.Errorf("line %v is %+v, want %+v", i, got, want[i])
+		}
+	}
+
+	// Just fits above the bottom margin.
+	bottom := lines[3].Y - ascent + height + margin
+	if _, fits := Layout(document.Text{Heading: "Title", Body: "One\n\nTwo", FontSize: 10}, w, bottom, margin); !fits {
+		t.Errorf("text doesn't fit on a page %v high", bottom)
+	}
+	if _, fits := Layout(document.Text{Heading: "Title", Body: "One\n\nTwo", FontSize: 10}, w, bottom-0.1, margin); fits {
+		t.Errorf("text fits on a page %v high", bottom-0.1)
+	}
+	if _, fits := Layout(document.Text{Body: strings.Repeat("Long text. ", 1000)}, w, h, margin); fits {
+		t.Errorf("long text fits")
+	}
+	if lines, fits := Layout(document.Text{}, w, h, margin); len(lines) != 0 || !fits {
+		t.Errorf("empty text: got %v lines, fits %v; want none which fit", len(lines), fits)
+	}
+	if lines, _ := Layout(document.Text{Body: "Hello"}, w, h, margin); lines[0].Size != DefaultFontSize {
+		t.Errorf("font size %v without one set, want %v", lines[0].Size, DefaultFontSize)
+	}
+}
+
+func TestMissing(t *testing.T) {
+	tests := []struct {
+		text document.Text
+		want string
+	}{
+		{document.Text{Heading: "Ünïcödé", Body: "Ελληνικά, кириллица\t–"}, ""},
+		{document.Text{Heading: "日本", Body: "日本語 and 🙂"}, "日本語🙂"},
+		{document.Text{Body: "a b　c"}, ""},
+	}
+	for _, tt := range tests {
+		if got := string(Missing(tt.text)); got != tt.want {
+			t.Errorf("Missing(%+v) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
@@ -1,60 +1,150 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	_ "image/jpeg"
+	"image/jpeg"
 	_ "image/png"
+	"log"
 	"math"
+	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/storage"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 	p4p "github.com/pic4pdf/lib-p4p"
 	_ "golang.org/x/image/webp"
 
+	"github.com/pic4pdf/pic4pdf/internal/archive"
+	"github.com/pic4pdf/pic4pdf/internal/document"
+	"github.com/pic4pdf/pic4pdf/internal/export"
 	"github.com/pic4pdf/pic4pdf/internal/gui"
+	"github.com/pic4pdf/pic4pdf/internal/instance"
+	"github.com/pic4pdf/pic4pdf/internal/paste"
+	"github.com/pic4pdf/pic4pdf/internal/pdf"
+	"github.com/pic4pdf/pic4pdf/internal/raw"
+	"github.com/pic4pdf/pic4pdf/internal/sign"
 )
 
+// Reports whether name has the extension of a supported image format.
+func validFilename(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".png" || ext == ".jpg" || ext == ".jpeg" || ext == ".webp" || raw.IsRaw(name)
+}
+
+// Formats a size in bytes for display.
+func formatSize(n int64) string {
+	switch {
+	case n < 1000:
+		return fmt.Sprintf("%v B", n)
+	case n < 1000*1000:
+		return fmt.Sprintf("%.0f kB", float64(n)/1000)
+	default:
+		return fmt.Sprintf("%.1f MB", float64(n)/1000/1000)
+	}
+}
+
+// Runs fn on the goroutine which handles the input events of w, like taps
+// and shortcuts, so that it doesn't race with them.
+func queueEvent(w fyne.Window, fn func()) {
+	// The desktop driver has no exported API for this.
+	if q, ok := w.(interface{ QueueEvent(func()) }); ok {
+		q.QueueEvent(fn)
+		return
+	}
+	fn()
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		os.Exit(runExport(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "verify" {
+		os.Exit(runVerify(os.Args[2:]))
+	}
+	inst, forwarded, err := instance.Start(os.Args[1:])
+	if forwarded {
+		// The running instance takes care of our arguments.
+		return
+	}
+	if err != nil {
+		log.Println("Single instance:", err)
+	} else {
+		defer inst.Close()
+	}
+
 	a := app.NewWithID("com.pic4pdf")
 	w := a.NewWindow("pic4pdf")
 	w.Resize(fyne.NewSize(800, 600))
 
-	validFilename := func(name string) bool {
-		ext := strings.ToLower(filepath.Ext(name))
-		return ext == ".png" || ext == ".jpg" || ext == ".jpeg" || ext == ".webp"
-	}
-
 	fileSel := gui.NewFileSelectorPersistent("Main")
 	fileSel.SetValidFilename(validFilename)
 	closeWatcher := fileSel.CreateSimpleWatcher()
 	defer closeWatcher()
 
+	if err := paste.RemoveStale(); err != nil {
+		log.Println("Remove stale pasted images:", err)
+	}
+	defer func() {
+		if err := paste.Cleanup(); err != nil {
+			log.Println("Cleanup pasted images:", err)
+		}
+	}()
+
 	fileOw := gui.NewFileOverview(fileSel)
+	fileOw.OnError = func(err error) {
+		dialog.ShowError(err, w)
+	}
+	fileOw.QueueEvent = func(fn func()) {
+		queueEvent(w, fn)
+	}
+	hist := fileOw.History
+	w.Canvas().AddShortcut(&fyne.ShortcutPaste{}, func(fyne.Shortcut) {
+		fileOw.PasteImage()
+	})
+	w.Canvas().AddShortcut(&fyne.ShortcutUndo{}, func(fyne.Shortcut) {
+		hist.Undo()
+	})
+	w.Canvas().AddShortcut(&fyne.ShortcutRedo{}, func(fyne.Shortcut) {
+		hist.Redo()
+	})
+	w.Canvas().AddShortcut(&desktop.CustomShortcut{
+		KeyName:  fyne.KeyZ,
+		Modifier: fyne.KeyModifierShortcutDefault | fyne.KeyModifierShift,
+	}, func(fyne.Shortcut) {
+		hist.Redo()
+	})
 
 	pv := gui.NewPDFPreview(fileOw, p4p.Millimeter, p4p.A4())
 	pv.OnError = func(err error) {
 		dialog.ShowError(err, w)
 	}
 
-	w.SetOnDropped(func(_ fyne.Position, uris []fyne.URI) {
+	// Selects all supported files in paths, reporting those which are not.
+	selectPaths := func(paths []string) {
 		added := 0
-		for _, uri := range uris {
-			path := uri.Path()
-			if !validFilename(path) {
-				continue
+		hist.Group("Add Files", func() {
+			for _, path := range paths {
+				if !validFilename(path) {
+					continue
+				}
+				added++
+				fileSel.Select(path)
 			}
-			added++
-			fileSel.Select(path)
-		}
-		if added != len(uris) {
-			n := len(uris) - added
+		})
+		if added != len(paths) {
+			n := len(paths) - added
 			var e error
 			if n == 1 {
 				e = fmt.Errorf("could not add file with unsupported format")
@@ -63,10 +153,165 @@ func main() {
 			}
 			dialog.ShowError(e, w)
 		}
+	}
+
+	openDropped := func(uris []fyne.URI) {
+		var files, dirs []string
+		subdirs := false
+		for _, uri := range uris {
+			path := uri.Path()
+			if st, err := archive.Stat(path); err == nil && st.IsDir() {
+				dirs = append(dirs, path)
+				subdirs = subdirs || hasSubdirs(path)
+			} else {
+				files = append(files, path)
+			}
+		}
+		if len(dirs) == 0 {
+			selectPaths(files)
+			return
+		}
+		addDropped := func(recursive bool) {
+			var paths []string
+			skipped := 0
+			for _, path := range files {
+				if validFilename(path) {
+					paths = append(paths, path)
+				} else {
+					skipped++
+				}
+			}
+			var errs []error
+			for _, dir := range dirs {
+				imgs, n, err := walkImages(dir, recursive, validFilename)
+				paths = append(paths, imgs...)
+				skipped += n
+				if err != nil {
+					errs = append(errs, err)
+				}
+			}
+			hist.Group("Add Folder", func() {
+				for _, path := range paths {
+					fileSel.Select(path)
+				}
+			})
+			msg := fmt.Sprintf("Added %v files, skipped %v with unsupported format.", len(paths), skipped)
+			if err := errors.Join(errs...); err != nil {
+				dialog.ShowError(fmt.Errorf("%v\nSome folders could not be read:\n%w", msg, err), w)
+			} else {
+				dialog.ShowInformation("Folder Added", msg, w)
+			}
+		}
+		if subdirs {
+			dialog.ShowConfirm("Add Folder", "Also add images from subfolders?", addDropped, w)
+		} else {
+			addDropped(false)
+		}
+	}
+	// Called on the main thread, unlike other input events.
+	w.SetOnDropped(func(_ fyne.Position, uris []fyne.URI) {
+		queueEvent(w, func() { openDropped(uris) })
 	})
 
+	openArgs := func(args []string) {
+		if len(args) == 0 {
+			return
+		}
+		paths, startDir, err := argPaths(args, validFilename)
+		if err != nil {
+			dialog.ShowError(err, w)
+		}
+		if startDir != "" {
+			fileSel.SetPath(startDir)
+		}
+		selectPaths(paths)
+	}
+	openArgs(os.Args[1:])
+	if inst != nil {
+		inst.SetOnOpen(func(args []string) {
+			queueEvent(w, func() { openArgs(args) })
+			w.RequestFocus()
+		})
+	}
+
+	const (
+		padNone        = "None"
+		padEven        = "Even Count"
+		padMultipleOf4 = "Multiple of 4"
+		dpiUnlimited   = "Unlimited"
+		coloursKeep    = "Keep"
+		coloursGray    = "Grayscale"
+		coloursBW      = "Black & White"
+		encAuto        = "Automatic"
+		encLossy       = "Lossy (JPEG)"
+		encLossless    = "Lossless"
+	)
+	var padSel *widget.Select
+	var pageBookmarks *widget.Check
+	// Size in bytes the PDF is reduced to on export, empty for no limit.
+	var targetSizeEntry *widget.Entry
+	// Returns the export options set, without metadata.
+	var exportOptions func() export.Options
+	// Estimates the output size in the background, cancelling a previous
+	// estimate.
+	var updateEstimate func()
+	// Returns the metadata entered and whether the creation date should be
+	// the earliest EXIF date.
+	var currentMetadata func() (m export.Metadata, exifDate bool)
+	// Returns the signature set up, loading the key, or nil if none is.
+	var currentSignature func() (*export.Signature, error)
 	var options *widget.Accordion
 	{
+		// Option changes are recorded in the undo history by comparing
+		// snapshots of all options before and after each change.
+		type optionsState struct {
+			pageSizeName string
+			pageSize     p4p.PageSize
+			unit         string
+			layout       string
+			scale        float64
+			padTo        string
+			margin       float64
+			bookmarks    bool
+			colours      string
+			maxDPI       string
+			quality      float64
+			photos       string
+			other        string
+			quantize     bool
+			targetSize   string
+			pdfa         bool
+			// Passwords are left out, so they are only kept in their
+			// entries.
+			allowPrint  bool
+			allowCopy   bool
+			allowModify bool
+		}
+		var currentOptions func() optionsState
+		var applyOptions func(optionsState)
+		var lastOptions optionsState
+		// Suppresses recording while options are initialized or one change
+		// triggers others.
+		recordingSuspended := true
+		recordOptions := func(name string, merge bool) {
+			if updateEstimate != nil {
+				updateEstimate()
+			}
+			if recordingSuspended || hist.Applying() {
+				return
+			}
+			prev, next := lastOptions, currentOptions()
+			if prev == next {
+				return
+			}
+			lastOptions = next
+			add := hist.Add
+			if merge {
+				add = hist.AddMerge
+			}
+			add(name, func() { applyOptions(prev) }, func() { applyOptions(next) }, nil)
+		}
+
 		var scaleSld *widget.Slider
 		layoutModeSel := widget.NewSelect(
 			[]string{"Center", "Fill", "Fit"},
@@ -79,7 +324,11 @@ func main() {
 				case "Fit":
 					pv.SetLayout(p4p.Fit)
 				}
+				suspended := recordingSuspended
+				recordingSuspended = true
 				scaleSld.SetValue(1)
+				recordingSuspended = suspended
+				recordOptions("Layout Mode", false)
 			},
 		)
 		layoutModeSel.Selected = "Fit"
@@ -98,6 +347,7 @@ func main() {
 		}
 		scaleSld.OnChangeEnded = func(v float64) {
 			pv.SetScale(v)
+			recordOptions("Scale", false)
 		}
 		scaleReset = widget.NewButtonWithIcon("", theme.ContentUndoIcon(), func() {
 			scaleSld.SetValue(1)
@@ -113,6 +363,7 @@ func main() {
 			ps := pv.PageSize.Convert(pv.Unit)
 			ps.W = v
 			pv.SetPageSize(ps)
+			recordOptions("Page Size", true)
 		}
 		pageSizeH := widget.NewEntry()
 		pageSizeH.Scroll = container.ScrollNone
@@ -123,17 +374,30 @@ func main() {
 			ps := pv.PageSize.Convert(pv.Unit)
 			ps.H = v
 			pv.SetPageSize(ps)
+			recordOptions("Page Size", true)
 		}
+		marginEntry := widget.NewEntry()
+		marginEntry.Scroll = container.ScrollNone
+		marginEntry.Wrapping = fyne.TextWrapOff
+		marginEntry.OnChanged = func(s string) {
+			v, _ := strconv.ParseFloat(s, 64)
+			pv.SetMargin(v * float64(pv.Unit))
+			recordOptions("Text Margin", true)
+		}
+		marginUnit := widget.NewLabel("")
 		updatePageSize := func() {
 			ps := pv.PageSize.Convert(pv.Unit)
 			pageSizeW.Text = strconv.FormatFloat(math.Round(ps.W*100)/100, 'f', -1, 64)
 			pageSizeW.Refresh()
 			pageSizeH.Text = strconv.FormatFloat(math.Round(ps.H*100)/100, 'f', -1, 64)
 			pageSizeH.Refresh()
+			marginEntry.Text = strconv.FormatFloat(math.Round(pv.Margin/float64(pv.Unit)*100)/100, 'f', -1, 64)
+			marginEntry.Refresh()
 		}
 		pageSizeRotate := widget.NewButtonWithIcon("", theme.MediaReplayIcon(), func() {
 			pv.SetPageSize(pv.PageSize.Rotate())
 			updatePageSize()
+			recordOptions("Rotate Page", false)
 		})
 		pageSizeUnitSel := widget.NewSelect(
 			[]string{"pt", "mm", "cm", "in"},
@@ -148,10 +412,13 @@ func main() {
 				case "in":
 					pv.SetUnit(p4p.Inch)
 				}
+				marginUnit.SetText(s)
 				updatePageSize()
+				recordOptions("Unit", false)
 			},
 		)
 		pageSizeUnitSel.Selected = "mm"
+		marginUnit.SetText(pageSizeUnitSel.Selected)
 		pageSizeSel = widget.NewSelect(
 			[]string{"A4", "A5", "A6", "Letter", "Legal", "Tabloid", "A3", "A2", "A1", "Custom"},
 			func(s string) {
@@ -178,9 +445,194 @@ func main() {
 					return
 				}
 				updatePageSize()
+				recordOptions("Page Size", false)
 			},
 		)
 		pageSizeSel.SetSelected("A4")
+		padSel = widget.NewSelect(
+			[]string{padNone, padEven, padMultipleOf4},
+			func(string) {
+				recordOptions("Pad Pages", false)
+			},
+		)
+		padSel.Selected = padNone
+		coloursSel := widget.NewSelect(
+			[]string{coloursKeep, coloursGray, coloursBW},
+			func(s string) {
+				switch s {
+				case coloursKeep:
+					pv.SetColourMode(document.KeepColours)
+				case coloursGray:
+					pv.SetColourMode(document.Grayscale)
+				case coloursBW:
+					pv.SetColourMode(document.BlackAndWhite)
+				}
+				recordOptions("Colours", false)
+			},
+		)
+		coloursSel.Selected = coloursKeep
+		pageBookmarks = widget.NewCheck("One per page, named after the file", func(bool) {
+			recordOptions("Bookmark Pages", false)
+		})
+		maxDPISel := widget.NewSelect(
+			[]string{dpiUnlimited, "600", "300", "200", "150", "96"},
+			func(string) {
+				recordOptions("Max DPI", false)
+			},
+		)
+		maxDPISel.Selected = dpiUnlimited
+		qualityLabel := widget.NewLabel(strconv.Itoa(jpeg.DefaultQuality))
+		qualitySld := widget.NewSlider(1, 100)
+		qualitySld.Value = jpeg.DefaultQuality
+		qualitySld.OnChanged = func(v float64) {
+			qualityLabel.SetText(strconv.Itoa(int(v)))
+		}
+		qualitySld.OnChangeEnded = func(float64) {
+			recordOptions("JPEG Quality", false)
+		}
+		photoEncSel := widget.NewSelect(
+			[]string{encAuto, encLossy, encLossless},
+			func(string) {
+				recordOptions("Photo Encoding", false)
+			},
+		)
+		photoEncSel.Selected = encAuto
+		otherEncSel := widget.NewSelect(
+			[]string{encAuto, encLossy, encLossless},
+			func(string) {
+				recordOptions("Image Encoding", false)
+			},
+		)
+		otherEncSel.Selected = encAuto
+		quantizeCheck := widget.NewCheck("Reduce colours of graphics", func(bool) {
+			recordOptions("Reduce Colours", false)
+		})
+		userPassword := widget.NewPasswordEntry()
+		userPassword.SetPlaceHolder("None")
+		ownerPassword := widget.NewPasswordEntry()
+		ownerPassword.SetPlaceHolder("Random, fixing the permissions")
+		allowPrint := widget.NewCheck("Printing", func(bool) {
+			recordOptions("Allow Printing", false)
+		})
+		allowPrint.Checked = true
+		allowCopy := widget.NewCheck("Copying", func(bool) {
+			recordOptions("Allow Copying", false)
+		})
+		allowCopy.Checked = true
+		allowModify := widget.NewCheck("Modifying", func(bool) {
+			recordOptions("Allow Modifying", false)
+		})
+		allowModify.Checked = true
+		docPDFA := widget.NewCheck("PDF/A-2b for archiving", func(bool) {
+			recordOptions("PDF/A", false)
+		})
+		targetSizeEntry = widget.NewEntry()
+		targetSizeEntry.SetPlaceHolder("None, e.g. 10 MB")
+		targetSizeEntry.OnChanged = func(string) {
+			recordOptions("Target Size", true)
+		}
+		encoding := func(s string) export.Encoding {
+			switch s {
+			case encLossy:
+				return export.Lossy
+			case encLossless:
+				return export.Lossless
+			}
+			return export.Auto
+		}
+		exportOptions = func() export.Options {
+			opts := export.Options{
+				PageSize: pv.PageSize,
+				Image: p4p.ImageOptions{
+					Mode:  pv.Layout,
+					Scale: pv.Scale,
+				},
+				Margin:        pv.Margin,
+				PageBookmarks: pageBookmarks.Checked,
+				ColourMode:    pv.ColourMode,
+				Quality:       int(qualitySld.Value),
+				PhotoEncoding: encoding(photoEncSel.Selected),
+				OtherEncoding: encoding(otherEncSel.Selected),
+				Quantize:      quantizeCheck.Checked,
+				PDFA:          docPDFA.Checked,
+			}
+			switch padSel.Selected {
+			case padEven:
+				opts.PadTo = 2
+			case padMultipleOf4:
+				opts.PadTo = 4
+			}
+			opts.MaxDPI, _ = strconv.ParseFloat(maxDPISel.Selected, 64)
+			enc := pdf.Encryption{
+				UserPassword:  userPassword.Text,
+				OwnerPassword: ownerPassword.Text,
+			}
+			for perm, check := range map[pdf.Permissions]*widget.Check{
+				pdf.PermitPrint:  allowPrint,
+				pdf.PermitCopy:   allowCopy,
+				pdf.PermitModify: allowModify,
+			} {
+				if check.Checked {
+					enc.Permissions |= perm
+				}
+			}
+			if enc.UserPassword != "" || enc.OwnerPassword != "" || enc.Permissions != pdf.PermitAll {
+				opts.Encryption = &enc
+			}
+			return opts
+		}
+		currentOptions = func() optionsState {
+			return optionsState{
+				pageSizeName: pageSizeSel.Selected,
+				pageSize:     pv.PageSize,
+				unit:         pageSizeUnitSel.Selected,
+				layout:       layoutModeSel.Selected,
+				scale:        pv.Scale,
+				padTo:        padSel.Selected,
+				margin:       pv.Margin,
+				bookmarks:    pageBookmarks.Checked,
+				colours:      coloursSel.Selected,
+				maxDPI:       maxDPISel.Selected,
+				quality:      qualitySld.Value,
+				photos:       photoEncSel.Selected,
+				other:        otherEncSel.Selected,
+				quantize:     quantizeCheck.Checked,
+				targetSize:   targetSizeEntry.Text,
+				pdfa:         docPDFA.Checked,
+				allowPrint:   allowPrint.Checked,
+				allowCopy:    allowCopy.Checked,
+				allowModify:  allowModify.Checked,
+			}
+		}
+		applyOptions = func(o optionsState) {
+			// Selecting a layout resets the scale and selecting a page size
+			// preset sets the page size, so restore those afterwards.
+			layoutModeSel.SetSelected(o.layout)
+			scaleSld.SetValue(o.scale)
+			pv.SetScale(o.scale)
+			pageSizeUnitSel.SetSelected(o.unit)
+			pageSizeSel.SetSelected(o.pageSizeName)
+			pv.SetPageSize(o.pageSize)
+			updatePageSize()
+			padSel.SetSelected(o.padTo)
+			pv.SetMargin(o.margin)
+			updatePageSize()
+			pageBookmarks.SetChecked(o.bookmarks)
+			coloursSel.SetSelected(o.colours)
+			maxDPISel.SetSelected(o.maxDPI)
+			qualitySld.SetValue(o.quality)
+			photoEncSel.SetSelected(o.photos)
+			otherEncSel.SetSelected(o.other)
+			quantizeCheck.SetChecked(o.quantize)
+			targetSizeEntry.SetText(o.targetSize)
+			docPDFA.SetChecked(o.pdfa)
+			allowPrint.SetChecked(o.allowPrint)
+			allowCopy.SetChecked(o.allowCopy)
+			allowModify.SetChecked(o.allowModify)
+			lastOptions = currentOptions()
+		}
+		lastOptions = currentOptions()
+		recordingSuspended = false
 		pageSizeCustomize := container.NewBorder(
 			nil, nil,
 			container.NewHBox(pageSizeW, widget.NewLabel("x"), pageSizeH),
@@ -191,10 +643,254 @@ func main() {
 			widget.NewFormItem("Page", container.NewVBox(pageSizeSel, pageSizeCustomize)),
 			widget.NewFormItem("Layout Mode", layoutModeSel),
 			widget.NewFormItem("Scale", container.NewBorder(nil, nil, scaleLabel, scaleReset, scaleSld)),
+			widget.NewFormItem("Colours", coloursSel),
+			widget.NewFormItem("Pad Pages", padSel),
+			widget.NewFormItem("Text Margin", container.NewBorder(nil, nil, nil, marginUnit, marginEntry)),
+			widget.NewFormItem("Bookmarks", pageBookmarks),
 		)
 		optsItem := widget.NewAccordionItem("Options", form)
 		optsItem.Open = true
-		options = widget.NewAccordion(optsItem)
+		compressionItem := widget.NewAccordionItem("Compression", widget.NewForm(
+			widget.NewFormItem("Max DPI", maxDPISel),
+			widget.NewFormItem("JPEG Quality", container.NewBorder(nil, nil, nil, qualityLabel, qualitySld)),
+			widget.NewFormItem("Photos", photoEncSel),
+			widget.NewFormItem("Other Images", otherEncSel),
+			widget.NewFormItem("", quantizeCheck),
+			widget.NewFormItem("Target Size", targetSizeEntry),
+		))
+
+		docTitle := widget.NewEntry()
+		docAuthor := widget.NewEntry()
+		docSubject := widget.NewEntry()
+		docKeywords := widget.NewEntry()
+		docKeywords.SetPlaceHolder("Comma-separated")
+		docCreator := widget.NewEntry()
+		docCreator.SetText("pic4pdf")
+		docExifDate := widget.NewCheck("Earliest photo date (EXIF)", nil)
+		currentMetadata = func() (export.Metadata, bool) {
+			return export.Metadata{
+				Title:    strings.TrimSpace(docTitle.Text),
+				Author:   strings.TrimSpace(docAuthor.Text),
+				Subject:  strings.TrimSpace(docSubject.Text),
+				Keywords: strings.TrimSpace(docKeywords.Text),
+				Creator:  strings.TrimSpace(docCreator.Text),
+			}, docExifDate.Checked
+		}
+		docItem := widget.NewAccordionItem("Document", widget.NewForm(
+			widget.NewFormItem("Title", docTitle),
+			widget.NewFormItem("Author", docAuthor),
+			widget.NewFormItem("Subject", docSubject),
+			widget.NewFormItem("Keywords", docKeywords),
+			widget.NewFormItem("Creator", docCreator),
+			widget.NewFormItem("Creation Date", docExifDate),
+			widget.NewFormItem("Format", docPDFA),
+		))
+		securityItem := widget.NewAccordionItem("Security", widget.NewForm(
+			widget.NewFormItem("Open Password", userPassword),
+			widget.NewFormItem("Owner Password", ownerPassword),
+			widget.NewFormItem("Allow", container.NewVBox(allowPrint, allowCopy, allowModify)),
+		))
+
+		signKey := widget.NewEntry()
+		signKey.SetPlaceHolder("None, .p12, .pfx or .pem")
+		signCert := widget.NewEntry()
+		signCert.SetPlaceHolder("Included in the key file")
+		// Selects a file for entry.
+		browse := func(entry *widget.Entry) *widget.Button {
+			return widget.NewButtonWithIcon("", theme.FolderOpenIcon(), func() {
+				dialog.ShowFileOpen(func(rc fyne.URIReadCloser, err error) {
+					if err != nil {
+						dialog.ShowError(err, w)
+						return
+					}
+					if rc != nil {
+						rc.Close()
+						entry.SetText(rc.URI().Path())
+					}
+				}, w)
+			})
+		}
+		signPassword := widget.NewPasswordEntry()
+		signPassword.SetPlaceHolder("None")
+		stampPage := widget.NewEntry()
+		stampPage.SetPlaceHolder("Last")
+		stampPage.Disable()
+		stamp := widget.NewCheck("Show stamp on page", func(b bool) {
+			if b {
+				stampPage.Enable()
+			} else {
+				stampPage.Disable()
+			}
+		})
+		currentSignature = func() (*export.Signature, error) {
+			if strings.TrimSpace(signKey.Text) == "" {
+				return nil, nil
+			}
+			sig := &export.Signature{}
+			if stamp.Checked {
+				sig.StampPage = -1
+				if s := strings.TrimSpace(stampPage.Text); s != "" {
+					n, err := strconv.Atoi(s)
+					if err != nil || n < 1 {
+						return nil, fmt.Errorf("invalid page number '%v' for the signature stamp", s)
+					}
+					sig.StampPage = n
+				}
+			}
+			var err error
+			sig.Signer, err = sign.Load(strings.TrimSpace(signKey.Text), strings.TrimSpace(signCert.Text), signPassword.Text)
+			if err != nil {
+				return nil, fmt.Errorf("can't load signing key: %w", err)
+			}
+			return sig, nil
+		}
+		signItem := widget.NewAccordionItem("Signature", widget.NewForm(
+			widget.NewFormItem("Key", container.NewBorder(nil, nil, nil, browse(signKey), signKey)),
+			widget.NewFormItem("Certificate", container.NewBorder(nil, nil, nil, browse(signCert), signCert)),
+			widget.NewFormItem("Key Password", signPassword),
+			widget.NewFormItem("Stamp", container.NewBorder(nil, nil, stamp, nil, stampPage)),
+		))
+		options = widget.NewAccordion(optsItem, compressionItem, docItem, securityItem, signItem)
+		options.MultiOpen = true
+	}
+
+	exportButton := widget.NewButtonWithIcon("Export PDF", theme.DocumentSaveIcon(), func() {
+		if fileOw.NumSelected() == 0 {
+			dialog.ShowError(fmt.Errorf("no images selected"), w)
+			return
+		}
+		target, err := parseTargetSize(targetSizeEntry.Text)
+		if err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		sig, err := currentSignature()
+		if err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		save := dialog.NewFileSave(func(wc fyne.URIWriteCloser, err error) {
+			if err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			if wc == nil {
+				// Cancelled.
+				return
+			}
+			opts := exportOptions()
+			pages := fileOw.Pages()
+			meta, exifDate := currentMetadata()
+			prog := dialog.NewProgressInfinite("Export PDF", "Writing "+wc.URI().Name()+"...", w)
+			prog.Show()
+			go func() {
+				defer prog.Hide()
+				if exifDate {
+					// Reading EXIF of many images takes a while.
+					meta.CreationDate = export.EarliestExifDate(pages)
+				}
+				opts.Metadata = meta
+				opts.Signature = sig
+				var notMet *export.TargetSizeError
+				if target > 0 {
+					// Writes the smallest PDF possible if the target can't
+					// be met.
+					fitted, _, err := export.FitSize(context.Background(), pages, opts, target)
+					if err != nil && !errors.As(err, &notMet) {
+						wc.Close()
+						dialog.ShowError(err, w)
+						return
+					}
+					opts = fitted
+				}
+				err := export.Write(wc, pages, opts)
+				if cerr := wc.Close(); err == nil {
+					err = cerr
+				}
+				if err != nil {
+					dialog.ShowError(err, w)
+				} else if notMet != nil {
+					dialog.ShowInformation("Target Size Not Met", fmt.Sprintf(
+						"The PDF can't be made smaller than %v, so it is larger than the target of %v.",
+						formatSize(notMet.Closest), formatSize(notMet.Target)), w)
+				}
+			}()
+		}, w)
+		save.SetFileName("output.pdf")
+		save.SetFilter(storage.NewExtensionFileFilter([]string{".pdf"}))
+		save.Show()
+	})
+	exportButton.Importance = widget.HighImportance
+
+	estimateLabel := widget.NewLabel("")
+	var cancelEstimate context.CancelFunc
+	var estimateTimer *time.Timer
+	// Options as of the last change, for use in goroutines.
+	var latestOpts export.Options
+	var latestOptsMu sync.Mutex
+	updateEstimate = func() {
+		opts := exportOptions()
+		latestOptsMu.Lock()
+		latestOpts = opts
+		latestOptsMu.Unlock()
+		if cancelEstimate != nil {
+			cancelEstimate()
+		}
+		if estimateTimer != nil {
+			estimateTimer.Stop()
+		}
+		if fileOw.NumSelected() == 0 {
+			estimateLabel.SetText("")
+			return
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancelEstimate = cancel
+		pages := fileOw.Pages()
+		opts.Metadata, _ = currentMetadata()
+		target, _ := parseTargetSize(targetSizeEntry.Text)
+		// Wait for changes to settle, as estimating encodes all images.
+		estimateTimer = time.AfterFunc(500*time.Millisecond, func() {
+			size, err := export.Size(ctx, pages, opts)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				estimateLabel.SetText("Estimated size: unknown")
+				return
+			}
+			if target > 0 && size > target {
+				estimateLabel.SetText(fmt.Sprintf("Estimated size: %v, reducing...", formatSize(size)))
+				// Fits like the export does, which encodes all images a few
+				// times.
+				_, fitted, err := export.FitSize(ctx, pages, opts, target)
+				var notMet *export.TargetSizeError
+				switch {
+				case ctx.Err() != nil:
+				case errors.As(err, &notMet):
+					estimateLabel.SetText(fmt.Sprintf("Estimated size: %v, %v on export (target not met)",
+						formatSize(size), formatSize(notMet.Closest)))
+				case err != nil:
+					estimateLabel.SetText("Estimated size: unknown")
+				default:
+					estimateLabel.SetText(fmt.Sprintf("Estimated size: %v, reduced to %v on export",
+						formatSize(size), formatSize(fitted)))
+				}
+				return
+			}
+			estimateLabel.SetText("Estimated size: " + formatSize(size))
+		})
+	}
+	pv.OnChanged = updateEstimate
+	updateEstimate()
+	fileOw.PageTooltip = func(page document.Page) string {
+		latestOptsMu.Lock()
+		opts := latestOpts
+		latestOptsMu.Unlock()
+		desc, err := export.DescribeCompression(page, opts)
+		if err != nil || desc == "" {
+			return ""
+		}
+		return "Compression: " + desc
 	}
 
 	split := container.NewHSplit(
@@ -203,7 +899,7 @@ func main() {
 			fileOw,
 		),
 		container.NewBorder(
-			nil, options, nil, nil, pv,
+			nil, container.NewVBox(estimateLabel, options, exportButton), nil, nil, pv,
 		),
 	)
 	split.Offset = 0.6
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pic4pdf/pic4pdf/internal/archive"
+	"github.com/pic4pdf/pic4pdf/internal/natsort"
+)
+
+// Limits how deep walkImages descends, as a last resort against cycles.
+const maxWalkDepth = 64
+
+// Returns the supported images inside dir in natural order, descending into
+// subdirectories if recursive is set. dir may be an archive, but archives
+// inside of it are skipped.
+//
+// skipped is the number of files with an unsupported format. Directories
+// that can't be read are reported in err, but don't stop the walk.
+func walkImages(dir string, recursive bool, validFilename func(name string) bool) (paths []string, skipped int, err error) {
+	var errs []error
+	// Directories visited so far, to detect symlink loops.
+	var visited []os.FileInfo
+	var walk func(dir string, depth int)
+	walk = func(dir string, depth int) {
+		if st, err := os.Stat(dir); err == nil {
+			for _, v := range visited {
+				if os.SameFile(st, v) {
+					return
+				}
+			}
+			visited = append(visited, st)
+		}
+		ents, err := archive.ReadDir(dir)
+		if err != nil {
+			errs = append(errs, err)
+			return
+		}
+		sort.SliceStable(ents, func(i, j int) bool {
+			return natsort.Less(ents[i].Name(), ents[j].Name())
+		})
+		for _, ent := range ents {
+			if strings.HasPrefix(ent.Name(), ".") {
+				continue
+			}
+			p := filepath.Join(dir, ent.Name())
+			isDir := ent.IsDir()
+			if ent.Type()&fs.ModeSymlink != 0 {
+				st, err := archive.Stat(p)
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				isDir = st.IsDir()
+			}
+			if isDir && !isArchiveFile(p) {
+				if recursive && depth < maxWalkDepth {
+					walk(p, depth+1)
+				}
+				continue
+			}
+			if validFilename(ent.Name()) {
+				paths = append(paths, p)
+			} else {
+				skipped++
+			}
+		}
+	}
+	walk(dir, 0)
+	return paths, skipped, errors.Join(errs...)
+}
+
+// Reports whether p is an archive file, which archive.ReadDir lists as a
+// directory.
+func isArchiveFile(p string) bool {
+	if !archive.IsArchive(p) {
+		return false
+	}
+	st, err := os.Stat(p)
+	return err == nil && st.Mode().IsRegular()
+}
+
+// Reports whether dir has any subdirectories, not counting archives.
+func hasSubdirs(dir string) bool {
+	ents, err := archive.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	for _, ent := range ents {
+		if ent.IsDir() && !isArchiveFile(filepath.Join(dir, ent.Name())) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returned by argPaths for files which aren't supported images.
+var errUnsupportedFormat = errors.New("unsupported format")
+
+// Turns command line arguments (paths or file:// URIs, as passed by
+// "Open with") into absolute paths. Directories and archives are replaced by
+// the images they contain. Files with an unsupported format are reported in
+// err and left out.
+//
+// startDir is the directory of the first valid argument, or that argument
+// itself if it is a directory or an archive.
+func argPaths(args []string, validFilename func(name string) bool) (paths []string, startDir string, err error) {
+	var errs []error
+	for _, arg := range args {
+		if u, err := url.Parse(arg); err == nil && u.Scheme == "file" {
+			arg = u.Path
+		}
+		p, err := filepath.Abs(arg)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		st, err := archive.Stat(p)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if st.IsDir() {
+			if startDir == "" {
+				startDir = p
+			}
+			imgs, _, err := walkImages(p, false, validFilename)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			paths = append(paths, imgs...)
+		} else if validFilename(p) {
+			if startDir == "" {
+				startDir = filepath.Dir(p)
+			}
+			paths = append(paths, p)
+		} else {
+			errs = append(errs, fmt.Errorf("%v: %w", p, errUnsupportedFormat))
+		}
+	}
+	return paths, startDir, errors.Join(errs...)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"archive/zip"
+	"errors"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Creates the files in dir. Names like "a.cbz/b" are entries of zip
+// archives.
+func writeTree(t *testing.T, dir string, files ...string) {
+	t.Helper()
+	archives := make(map[string][]string)
+	for _, f := range files {
+		if a, ent, ok := strings.Cut(f, ".cbz/"); ok {
+			archives[a+".cbz"] = append(archives[a+".cbz"], ent)
+			continue
+		}
+		p := filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(f), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for a, ents := range archives {
+		f, err := os.Create(filepath.Join(dir, a))
+		if err != nil {
+			t.Fatal(err)
+		}
+		zw := zip.NewWriter(f)
+		for _, ent := range ents {
+			w, err := zw.Create(ent)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.Write([]byte(ent))
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+}
+
+func TestArgPaths(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir,
+		"photo.jpg",
+		"notes.txt",
+		"scans/10.png", "scans/2.png", "scans/readme.md", "scans/sub/3.png",
+		"comic.cbz/2.jpg", "comic.cbz/10.jpg", "comic.cbz/info.txt",
+	)
+	abs := func(name string) string {
+		return filepath.Join(dir, name)
+	}
+	tests := []struct {
+		name         string
+		args         []string
+		want         []string
+		wantStartDir string
+		wantErr      error
+	}{
+		{"file URI", []string{(&url.URL{Scheme: "file", Path: abs("photo.jpg")}).String()},
+			[]string{abs("photo.jpg")}, dir, nil},
+		{"directory", []string{abs("scans")},
+			[]string{abs("scans/2.png"), abs("scans/10.png")}, abs("scans"), nil},
+		{"archive", []string{abs("comic.cbz")},
+			[]string{abs("comic.cbz/2.jpg"), abs("comic.cbz/10.jpg")}, abs("comic.cbz"), nil},
+		{"archive entry", []string{abs("comic.cbz/10.jpg")},
+			[]string{abs("comic.cbz/10.jpg")}, abs("comic.cbz"), nil},
+		{"unsupported file", []string{abs("notes.txt"), abs("photo.jpg")},
+			[]string{abs("photo.jpg")}, dir, errUnsupportedFormat},
+		{"missing path", []string{abs("missing.jpg"), abs("scans")},
+			[]string{abs("scans/2.png"), abs("scans/10.png")}, abs("scans"), fs.ErrNotExist},
+	}
+	for _, tt := range tests {
+		paths, startDir, err := argPaths(tt.args, validFilename)
+		if !slices.Equal(paths, tt.want) || startDir != tt.wantStartDir {
+			t.Errorf("%v: got %q in %q, want %q in %q", tt.name, paths, startDir, tt.want, tt.wantStartDir)
+		}
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%v: got error %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestWalkImages(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir,
+		"1.jpg", "notes.txt",
+		"a/2.jpg", "a/b/3.jpg", "a/b/notes.txt",
+		"a/comic.cbz/4.jpg",
+		"locked/5.jpg",
+	)
+	// A loop back to the parent, and a subfolder which can't be read.
+	if err := os.Symlink("..", filepath.Join(dir, "a/b/up")); err != nil {
+		t.Fatal(err)
+	}
+	locked := filepath.Join(dir, "locked")
+	if err := os.Chmod(locked, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(locked, 0o755)
+	// Root can read it anyway.
+	_, lockedErr := os.ReadDir(locked)
+
+	paths, skipped, err := walkImages(dir, true, validFilename)
+	want := []string{filepath.Join(dir, "1.jpg"), filepath.Join(dir, "a/2.jpg"), filepath.Join(dir, "a/b/3.jpg")}
+	if lockedErr == nil {
+		want = append(want, filepath.Join(dir, "locked/5.jpg"))
+	}
+	// The notes and the archive.
+	if !slices.Equal(paths, want) || skipped != 3 {
+		t.Errorf("got %q and %v skipped, want %q and 3", paths, skipped, want)
+	}
+	if lockedErr != nil && !errors.Is(err, fs.ErrPermission) || lockedErr == nil && err != nil {
+		t.Errorf("got error %v, want one for %v", err, locked)
+	}
+
+	paths, skipped, err = walkImages(dir, false, validFilename)
+	if !slices.Equal(paths, want[:1]) || skipped != 1 || err != nil {
+		t.Errorf("not recursive: got %q, %v skipped and error %v; want %q and 1", paths, skipped, err, want[:1])
+	}
+}